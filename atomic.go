@@ -0,0 +1,233 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yahoojapan/gongt/internal/ngtfile"
+)
+
+type (
+	// AtomicIndex serves searches from a read-only NGT index which can be
+	// replaced by a new index directory without stopping searches
+	AtomicIndex struct {
+		mu      *sync.RWMutex
+		cur     *generation
+		closing *sync.WaitGroup
+		stop    chan struct{}
+		stopped *sync.Once
+	}
+	// generation is an opened index with the searches using it
+	generation struct {
+		n        *NGT
+		path     string
+		inflight *sync.WaitGroup
+	}
+)
+
+// ErrAtomicIndexClosed raises using AtomicIndex after Close
+var ErrAtomicIndexClosed = errors.New("atomic index is closed")
+
+// NewAtomicIndex opens index directory at path read-only and returns AtomicIndex serving it.
+func NewAtomicIndex(path string) (*AtomicIndex, error) {
+	g, err := openGeneration(path)
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicIndex{
+		mu:      &sync.RWMutex{},
+		cur:     g,
+		closing: &sync.WaitGroup{},
+		stop:    make(chan struct{}),
+		stopped: &sync.Once{},
+	}, nil
+}
+
+func openGeneration(path string) (*generation, error) {
+	n := OpenReadOnly(path)
+	if errs := n.GetErrors(); len(errs) > 0 {
+		n.Close()
+		return nil, errs[0]
+	}
+	return &generation{
+		n:        n,
+		path:     path,
+		inflight: &sync.WaitGroup{},
+	}, nil
+}
+
+// acquire returns current generation and marks a use of it, caller must call release.
+func (a *AtomicIndex) acquire() (*generation, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.cur == nil {
+		return nil, ErrAtomicIndexClosed
+	}
+	a.cur.inflight.Add(1)
+	return a.cur, nil
+}
+
+func (g *generation) release() {
+	g.inflight.Done()
+}
+
+// Do calls fn with the current index. The index stays open until fn returns,
+// even if it is replaced meanwhile. fn must not modify or close the index.
+func (a *AtomicIndex) Do(fn func(n *NGT) error) error {
+	g, err := a.acquire()
+	if err != nil {
+		return err
+	}
+	defer g.release()
+	return fn(g.n)
+}
+
+// Search searches the current index.
+func (a *AtomicIndex) Search(vec []float64, size int, epsilon float64) ([]SearchResult, error) {
+	var result []SearchResult
+	err := a.Do(func(n *NGT) (err error) {
+		result, err = n.Search(vec, size, epsilon)
+		return err
+	})
+	return result, err
+}
+
+// GetVector returns vector stored in the current index.
+func (a *AtomicIndex) GetVector(id int) ([]float64, error) {
+	var vec []float64
+	err := a.Do(func(n *NGT) (err error) {
+		vec, err = n.GetVector(id)
+		return err
+	})
+	return vec, err
+}
+
+// Path returns the directory of the current index.
+func (a *AtomicIndex) Path() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.cur == nil {
+		return ""
+	}
+	return a.cur.path
+}
+
+// Reload opens index directory at path and swaps it with the current index.
+// Searches started before the swap finish on the old index, which is closed after them
+// in background. The current index is kept if path cannot be opened.
+func (a *AtomicIndex) Reload(path string) error {
+	g, err := openGeneration(path)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	old := a.cur
+	if old == nil {
+		a.mu.Unlock()
+		g.n.Close()
+		return ErrAtomicIndexClosed
+	}
+	a.cur = g
+	a.mu.Unlock()
+
+	a.retire(old)
+	return nil
+}
+
+// retire closes g after the searches using it finish.
+func (a *AtomicIndex) retire(g *generation) {
+	a.closing.Add(1)
+	go func() {
+		defer a.closing.Done()
+		g.inflight.Wait()
+		g.n.Close()
+	}()
+}
+
+// Watch polls dir every interval and reloads the latest generation, the subdirectory
+// with the greatest name, when it differs from the current index.
+// A generation is taken as complete when it has the manifest written by SaveIndex;
+// temporary and previous directories of SaveIndex are skipped.
+// Errors of reload are passed to onError if not nil. Watch stops on Close.
+//	a.Watch("/var/lib/items", time.Minute, func(err error) { log.Println(err) })
+func (a *AtomicIndex) Watch(dir string, interval time.Duration, onError func(error)) {
+	a.closing.Add(1)
+	go func() {
+		defer a.closing.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if path, ok := latestGeneration(dir); ok && path != a.Path() {
+				if err := a.Reload(path); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+			select {
+			case <-a.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// latestGeneration returns the complete subdirectory of dir with the greatest name.
+func latestGeneration(dir string) (string, bool) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+	// ReadDir returns entries sorted by name
+	for i := len(infos) - 1; i >= 0; i-- {
+		name := infos[i].Name()
+		// skip directories SaveIndex is writing or has replaced
+		if !infos[i].IsDir() || strings.Contains(name, tmpIndexSuffix) || strings.HasSuffix(name, PreviousIndexSuffix) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(filepath.Join(path, ngtfile.ManifestFile)); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// Close stops watching, waits for searches to finish and closes every index.
+func (a *AtomicIndex) Close() {
+	a.stopped.Do(func() {
+		close(a.stop)
+	})
+
+	a.mu.Lock()
+	old := a.cur
+	a.cur = nil
+	a.mu.Unlock()
+	if old != nil {
+		a.retire(old)
+	}
+	a.closing.Wait()
+}
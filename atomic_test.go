@@ -0,0 +1,151 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func createGeneration(path string, vecs [][]float64) error {
+	n := New(path).SetObjectType(Uint8).SetDimension(6).Open()
+	defer n.Close()
+	for _, v := range vecs {
+		if _, err := n.Insert(v); err != nil {
+			return err
+		}
+	}
+	return n.CreateAndSaveIndex(poolSize)
+}
+
+func TestAtomicIndexReload(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestAtomicIndexReload(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	gen1 := filepath.Join(tmpdir, "1")
+	gen2 := filepath.Join(tmpdir, "2")
+	if err := createGeneration(gen1, [][]float64{{1, 0, 0, 0, 0, 0}}); err != nil {
+		t.Errorf("Unexpected error: TestAtomicIndexReload(%v)", err)
+	}
+	if err := createGeneration(gen2, [][]float64{{0, 2, 0, 0, 0, 0}}); err != nil {
+		t.Errorf("Unexpected error: TestAtomicIndexReload(%v)", err)
+	}
+
+	a, err := NewAtomicIndex(gen1)
+	if err != nil {
+		t.Fatalf("Unexpected error: TestAtomicIndexReload(%v)", err)
+	}
+	defer a.Close()
+
+	// a search in flight keeps the old index open across the swap
+	started := make(chan struct{})
+	wait := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := a.Do(func(n *NGT) error {
+			close(started)
+			<-wait
+			_, err := n.Search([]float64{1, 0, 0, 0, 0, 0}, 1, DefaultEpsilon)
+			return err
+		})
+		if err != nil {
+			t.Errorf("Unexpected error: TestAtomicIndexReload(%v)", err)
+		}
+	}()
+	<-started
+
+	if err := a.Reload(gen2); err != nil {
+		t.Errorf("Unexpected error: TestAtomicIndexReload(%v)", err)
+	}
+	close(wait)
+	wg.Wait()
+
+	if got := a.Path(); got != gen2 {
+		t.Errorf("TestAtomicIndexReload(%v): %v, wanted: %v", gen2, got, gen2)
+	}
+	vec, err := a.GetVector(1)
+	if err != nil {
+		t.Errorf("Unexpected error: TestAtomicIndexReload(%v)", err)
+	}
+	if want := []float64{0, 2, 0, 0, 0, 0}; !reflect.DeepEqual(vec, want) {
+		t.Errorf("TestAtomicIndexReload(%v): %v, wanted: %v", gen2, vec, want)
+	}
+
+	// a broken directory keeps the current index
+	if err := a.Reload(filepath.Join(tmpdir, "missing")); err == nil {
+		t.Errorf("TestAtomicIndexReload(missing): no error, wanted: error")
+	}
+	if got := a.Path(); got != gen2 {
+		t.Errorf("TestAtomicIndexReload(missing): %v, wanted: %v", got, gen2)
+	}
+
+	a.Close()
+	if _, err := a.Search([]float64{1, 0, 0, 0, 0, 0}, 1, DefaultEpsilon); err != ErrAtomicIndexClosed {
+		t.Errorf("TestAtomicIndexReload(closed): %v, wanted: %v", err, ErrAtomicIndexClosed)
+	}
+}
+
+func TestAtomicIndexWatch(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestAtomicIndexWatch(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	gen1 := filepath.Join(tmpdir, "20170101")
+	gen2 := filepath.Join(tmpdir, "20170102")
+	if err := createGeneration(gen1, [][]float64{{1, 0, 0, 0, 0, 0}}); err != nil {
+		t.Errorf("Unexpected error: TestAtomicIndexWatch(%v)", err)
+	}
+
+	a, err := NewAtomicIndex(gen1)
+	if err != nil {
+		t.Fatalf("Unexpected error: TestAtomicIndexWatch(%v)", err)
+	}
+	defer a.Close()
+	a.Watch(tmpdir, 10*time.Millisecond, func(err error) {
+		t.Errorf("Unexpected error: TestAtomicIndexWatch(%v)", err)
+	})
+
+	// an incomplete generation without manifest is ignored
+	if err := os.MkdirAll(filepath.Join(tmpdir, "20170103"), 0755); err != nil {
+		t.Errorf("Unexpected error: TestAtomicIndexWatch(%v)", err)
+	}
+	if err := createGeneration(gen2, [][]float64{{0, 2, 0, 0, 0, 0}}); err != nil {
+		t.Errorf("Unexpected error: TestAtomicIndexWatch(%v)", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for a.Path() != gen2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := a.Path(); got != gen2 {
+		t.Errorf("TestAtomicIndexWatch(%v): %v, wanted: %v", gen2, got, gen2)
+	}
+}
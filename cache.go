@@ -0,0 +1,201 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"container/list"
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+)
+
+type (
+	// SearchCacheConfig is parameters for search result cache
+	SearchCacheConfig struct {
+		// Size is the maximum number of cached searches, cache is disabled if 0
+		Size int
+		// TTL is how long a result is kept, forever if 0
+		TTL time.Duration
+		// Quantum is the step query elements are rounded to in cache keys,
+		// queries differing less than it share results. Exact if 0
+		Quantum float64
+	}
+
+	// CacheStats is counters of search result cache
+	CacheStats struct {
+		Hits          uint64
+		Misses        uint64
+		Evictions     uint64
+		Invalidations uint64
+		Entries       int
+	}
+
+	// searchCache is LRU cache of Search results invalidated by any mutation
+	searchCache struct {
+		mu    *sync.Mutex
+		cfg   SearchCacheConfig
+		lru   *list.List
+		items map[string]*list.Element
+		// gen is incremented by invalidate, so searches started before it are not cached
+		gen   uint64
+		stats CacheStats
+	}
+	cacheEntry struct {
+		key     string
+		result  []SearchResult
+		expires time.Time
+	}
+)
+
+// HitRate returns the fraction of searches served from cache.
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func newSearchCache(cfg SearchCacheConfig) *searchCache {
+	return &searchCache{
+		mu:    &sync.Mutex{},
+		cfg:   cfg,
+		lru:   list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// key encodes search parameters and quantized query.
+func (c *searchCache) key(vec []float64, size int, epsilon float64) string {
+	buf := make([]byte, 16+8*len(vec))
+	binary.LittleEndian.PutUint64(buf, uint64(size))
+	binary.LittleEndian.PutUint64(buf[8:], math.Float64bits(epsilon))
+	for i, v := range vec {
+		var bits uint64
+		if c.cfg.Quantum > 0 {
+			bits = uint64(int64(math.Round(v / c.cfg.Quantum)))
+		} else {
+			bits = math.Float64bits(v)
+		}
+		binary.LittleEndian.PutUint64(buf[16+8*i:], bits)
+	}
+	return string(buf)
+}
+
+// get returns copy of cached result and the generation to pass to put.
+func (c *searchCache) get(key string) ([]SearchResult, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		e := elem.Value.(*cacheEntry)
+		if e.expires.IsZero() || time.Now().Before(e.expires) {
+			c.lru.MoveToFront(elem)
+			c.stats.Hits++
+			return append([]SearchResult(nil), e.result...), c.gen, true
+		}
+		c.lru.Remove(elem)
+		delete(c.items, key)
+	}
+	c.stats.Misses++
+	return nil, c.gen, false
+}
+
+// put caches result unless the cache is invalidated after generation gen.
+func (c *searchCache) put(key string, result []SearchResult, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	e := &cacheEntry{key: key, result: append([]SearchResult(nil), result...)}
+	if c.cfg.TTL > 0 {
+		e.expires = time.Now().Add(c.cfg.TTL)
+	}
+	if elem, ok := c.items[key]; ok {
+		elem.Value = e
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.cfg.Size {
+		elem := c.lru.Back()
+		c.lru.Remove(elem)
+		delete(c.items, elem.Value.(*cacheEntry).key)
+		c.stats.Evictions++
+	}
+}
+
+// invalidate drops every cached result.
+func (c *searchCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.stats.Invalidations++
+	c.lru.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+func (c *searchCache) snapshot() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	return stats
+}
+
+// SetSearchCache enables search result cache of the default NGT index
+func SetSearchCache(cfg SearchCacheConfig) *NGT {
+	return Get().SetSearchCache(cfg)
+}
+
+// SetSearchCache enables LRU cache of Search results keyed by quantized query, size and epsilon.
+// Insert, Remove and CreateIndex drop every cached result. Set zero Size to disable.
+//	ngt := gongt.New("index Path").SetSearchCache(gongt.SearchCacheConfig{Size: 10000, TTL: time.Minute}).Open()
+func (n *NGT) SetSearchCache(cfg SearchCacheConfig) *NGT {
+	n.mu.Lock()
+	if cfg.Size > 0 {
+		n.cache.Store(newSearchCache(cfg))
+	} else {
+		n.cache.Store(nil)
+	}
+	n.mu.Unlock()
+
+	return n
+}
+
+// SearchCacheStats returns counters of search result cache of the default NGT index
+func SearchCacheStats() CacheStats {
+	return Get().SearchCacheStats()
+}
+
+// SearchCacheStats returns counters of search result cache, zero if it is disabled.
+func (n *NGT) SearchCacheStats() CacheStats {
+	c := n.cache.Load()
+	if c == nil {
+		return CacheStats{}
+	}
+	return c.snapshot()
+}
+
+// invalidateCache drops cached results, caller must hold write lock.
+func (n *NGT) invalidateCache() {
+	if c := n.cache.Load(); c != nil {
+		c.invalidate()
+	}
+}
@@ -0,0 +1,213 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSearchCacheLRU(t *testing.T) {
+	c := newSearchCache(SearchCacheConfig{Size: 2, Quantum: 0.1})
+	results := [][]SearchResult{{{1, 0}}, {{2, 0}}, {{3, 0}}}
+	keys := []string{
+		c.key([]float64{1, 0}, 1, 0),
+		c.key([]float64{0, 1}, 1, 0),
+		c.key([]float64{1, 1}, 1, 0),
+	}
+	if got := c.key([]float64{1.01, 0}, 1, 0); got != keys[0] {
+		t.Errorf("TestSearchCacheLRU(quantum): different key for close queries")
+	}
+	if got := c.key([]float64{1, 0}, 2, 0); got == keys[0] {
+		t.Errorf("TestSearchCacheLRU(size): same key for different size")
+	}
+
+	for i := range keys[:2] {
+		c.put(keys[i], results[i], 0)
+	}
+	// keys[0] becomes most recently used, keys[1] is evicted
+	if got, _, ok := c.get(keys[0]); !ok || !reflect.DeepEqual(got, results[0]) {
+		t.Errorf("TestSearchCacheLRU(get): %v %v, wanted: %v", got, ok, results[0])
+	}
+	c.put(keys[2], results[2], 0)
+	if _, _, ok := c.get(keys[1]); ok {
+		t.Errorf("TestSearchCacheLRU(evicted): hit, wanted: miss")
+	}
+
+	_, gen, _ := c.get(keys[1])
+	c.invalidate()
+	if _, _, ok := c.get(keys[0]); ok {
+		t.Errorf("TestSearchCacheLRU(invalidated): hit, wanted: miss")
+	}
+	// a search started before invalidate is not cached
+	c.put(keys[1], results[1], gen)
+	if _, _, ok := c.get(keys[1]); ok {
+		t.Errorf("TestSearchCacheLRU(stale): hit, wanted: miss")
+	}
+
+	want := CacheStats{Hits: 1, Misses: 4, Evictions: 1, Invalidations: 1, Entries: 0}
+	if got := c.snapshot(); got != want {
+		t.Errorf("TestSearchCacheLRU(stats): %+v, wanted: %+v", got, want)
+	}
+	if got := want.HitRate(); got != 0.2 {
+		t.Errorf("TestSearchCacheLRU(hit rate): %v, wanted: %v", got, 0.2)
+	}
+}
+
+func TestSearchCacheTTL(t *testing.T) {
+	c := newSearchCache(SearchCacheConfig{Size: 1, TTL: 10 * time.Millisecond})
+	key := c.key([]float64{1}, 1, 0)
+	c.put(key, []SearchResult{{1, 0}}, 0)
+	if _, _, ok := c.get(key); !ok {
+		t.Errorf("TestSearchCacheTTL: miss, wanted: hit")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, _, ok := c.get(key); ok {
+		t.Errorf("TestSearchCacheTTL(expired): hit, wanted: miss")
+	}
+}
+
+func TestSearchCache(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestSearchCache(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	n := New(filepath.Join(tmpdir, "index")).SetObjectType(Uint8).SetDimension(6).
+		SetSearchCache(SearchCacheConfig{Size: 10}).Open()
+	defer n.Close()
+	if _, err := n.InsertCommit([]float64{1, 0, 0, 0, 0, 0}, poolSize); err != nil {
+		t.Errorf("Unexpected error: TestSearchCache(%v)", err)
+	}
+
+	query := []float64{0, 1, 0, 0, 0, 0}
+	first, err := n.Search(query, 1, DefaultEpsilon)
+	if err != nil {
+		t.Errorf("Unexpected error: TestSearchCache(%v)", err)
+	}
+	second, err := n.Search(query, 1, DefaultEpsilon)
+	if err != nil {
+		t.Errorf("Unexpected error: TestSearchCache(%v)", err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("TestSearchCache(cached): %v, wanted: %v", second, first)
+	}
+	if got := n.SearchCacheStats(); got.Hits != 1 || got.Misses != 1 {
+		t.Errorf("TestSearchCache(stats): %+v, wanted 1 hit and 1 miss", got)
+	}
+
+	// a nearer object inserted later must be found
+	id, err := n.InsertCommit(query, poolSize)
+	if err != nil {
+		t.Errorf("Unexpected error: TestSearchCache(%v)", err)
+	}
+	result, err := n.Search(query, 1, DefaultEpsilon)
+	if err != nil {
+		t.Errorf("Unexpected error: TestSearchCache(%v)", err)
+	}
+	if len(result) != 1 || result[0].ID != id {
+		t.Errorf("TestSearchCache(invalidated): %v, wanted: %v", result, id)
+	}
+}
+
+func TestSearchCacheReopen(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestSearchCacheReopen(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	query := []float64{0, 1, 0, 0, 0, 0}
+	other := New(filepath.Join(tmpdir, "other")).SetObjectType(Uint8).SetDimension(6).Open()
+	if _, err := other.Insert([]float64{9, 9, 9, 9, 9, 9}); err != nil {
+		t.Errorf("Unexpected error: TestSearchCacheReopen(%v)", err)
+	}
+	want, err := other.Insert(query)
+	if err != nil {
+		t.Errorf("Unexpected error: TestSearchCacheReopen(%v)", err)
+	}
+	if err := other.CreateAndSaveIndex(poolSize); err != nil {
+		t.Errorf("Unexpected error: TestSearchCacheReopen(%v)", err)
+	}
+	other.Close()
+
+	n := New(filepath.Join(tmpdir, "index")).SetObjectType(Uint8).SetDimension(6).
+		SetSearchCache(SearchCacheConfig{Size: 10}).Open()
+	defer n.Close()
+	if _, err := n.InsertCommit([]float64{1, 0, 0, 0, 0, 0}, poolSize); err != nil {
+		t.Errorf("Unexpected error: TestSearchCacheReopen(%v)", err)
+	}
+	if _, err := n.Search(query, 1, DefaultEpsilon); err != nil {
+		t.Errorf("Unexpected error: TestSearchCacheReopen(%v)", err)
+	}
+
+	// results of the previous index must not be returned after reopening
+	for _, open := range []func(*NGT) *NGT{(*NGT).Open, (*NGT).OpenReadOnly} {
+		if errs := open(n.SetIndexPath(filepath.Join(tmpdir, "other"))).GetErrors(); len(errs) != 0 {
+			t.Errorf("Unexpected error: TestSearchCacheReopen(%v)", errs)
+		}
+		result, err := n.Search(query, 1, DefaultEpsilon)
+		if err != nil {
+			t.Errorf("Unexpected error: TestSearchCacheReopen(%v)", err)
+		}
+		if len(result) != 1 || result[0].ID != want {
+			t.Errorf("TestSearchCacheReopen: %v, wanted: %v", result, want)
+		}
+		n.SetIndexPath(filepath.Join(tmpdir, "index")).Open()
+		if _, err := n.Search(query, 1, DefaultEpsilon); err != nil {
+			t.Errorf("Unexpected error: TestSearchCacheReopen(%v)", err)
+		}
+	}
+}
+
+func TestSearchCacheConcurrent(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestSearchCacheConcurrent(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	n := New(filepath.Join(tmpdir, "index")).SetDimension(6).Open()
+	defer n.Close()
+	if _, err := n.InsertCommit([]float64{1, 0, 0, 0, 0, 0}, 1); err != nil {
+		t.Fatalf("Unexpected error: TestSearchCacheConcurrent(%v)", err)
+	}
+
+	// toggling the cache while searching must not race
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			n.SetSearchCache(SearchCacheConfig{Size: i % 2})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if _, err := n.Search([]float64{1, 0, 0, 0, 0, 0}, 1, DefaultEpsilon); err != nil {
+			t.Errorf("Unexpected error: TestSearchCacheConcurrent(%v)", err)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,10 @@
+module github.com/yahoojapan/gongt/cmd/annconv
+
+go 1.21
+
+require (
+	github.com/yahoojapan/gongt v1.1.1
+	gonum.org/v1/hdf5 v0.0.0-20190227001252-83207889d689
+)
+
+replace github.com/yahoojapan/gongt => ../..
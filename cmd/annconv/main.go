@@ -0,0 +1,147 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Command annconv converts ann-benchmarks HDF5 files into gongt dataset files.
+//
+//	annconv <input.hdf5> [output.gds]
+//
+// It is a separate module so that only this command needs libhdf5.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yahoojapan/gongt/dataset"
+	"gonum.org/v1/hdf5"
+)
+
+func readMatrix(f *hdf5.File, name string, v interface{}) (int, int, error) {
+	dset, err := f.OpenDataset(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer dset.Close()
+	space := dset.Space()
+	defer space.Close()
+	dims, _, err := space.SimpleExtentDims()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(dims) != 2 {
+		return 0, 0, fmt.Errorf("%s: %d dimensional dataset, wanted: 2", name, len(dims))
+	}
+
+	size := space.SimpleExtentNPoints()
+	switch v := v.(type) {
+	case *[]float32:
+		*v = make([]float32, size)
+	case *[]int32:
+		*v = make([]int32, size)
+	}
+	if err = dset.Read(v); err != nil {
+		return 0, 0, err
+	}
+	return int(dims[0]), int(dims[1]), nil
+}
+
+func float32s(f *hdf5.File, name string) ([][]float64, error) {
+	var v []float32
+	row, col, err := readMatrix(f, name, &v)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]float64, row)
+	for i := range rows {
+		rows[i] = make([]float64, col)
+		for j := range rows[i] {
+			rows[i][j] = float64(v[i*col+j])
+		}
+	}
+	return rows, nil
+}
+
+func int32s(f *hdf5.File, name string) ([][]float64, error) {
+	var v []int32
+	row, col, err := readMatrix(f, name, &v)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]float64, row)
+	for i := range rows {
+		rows[i] = make([]float64, col)
+		for j := range rows[i] {
+			rows[i][j] = float64(v[i*col+j])
+		}
+	}
+	return rows, nil
+}
+
+func convert(input, output string) error {
+	f, err := hdf5.OpenFile(input, hdf5.F_ACC_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	w := dataset.NewWriter(out)
+	for _, name := range []string{dataset.Train, dataset.Test, dataset.Distances} {
+		rows, err := float32s(f, name)
+		if err != nil {
+			out.Close()
+			return err
+		}
+		w.AddFloat32(name, rows)
+	}
+	rows, err := int32s(f, dataset.Neighbors)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	w.AddInt32(dataset.Neighbors, rows)
+
+	if err = w.Flush(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: annconv <input.hdf5> [output.gds]")
+	}
+	flag.Parse()
+	if flag.NArg() < 1 || flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	input := flag.Arg(0)
+	output := strings.TrimSuffix(input, ".hdf5") + ".gds"
+	if flag.NArg() == 2 {
+		output = flag.Arg(1)
+	}
+	if err := convert(input, output); err != nil {
+		fmt.Fprintf(os.Stderr, "annconv: %v\n", err)
+		os.Exit(1)
+	}
+}
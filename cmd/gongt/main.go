@@ -0,0 +1,271 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+// Command gongt provides maintenance tools for NGT index directories.
+//
+//	gongt verify <index>
+//	gongt info <index>
+//	gongt optimize [-outgoing n] [-incoming n] [-prune] [-dataset file] <index> <output>
+//	gongt export [-format fvecs|bvecs|ivecs|npy|csv|tsv|ssv] [-ids] <index> [output]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/yahoojapan/gongt"
+	"github.com/yahoojapan/gongt/dataset"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) int
+}
+
+var commands = []command{
+	{"verify", "verify <index>", verify},
+	{"info", "info <index>", info},
+	{"optimize", "optimize [-outgoing n] [-incoming n] [-prune] [-dataset file] <index> <output>", optimize},
+	{"export", "export [-format name] [-ids] <index> [output]", export},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: gongt <command> [arguments]")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "\tgongt %s\n", c.usage)
+	}
+}
+
+func newFlagSet(usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(usage, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: gongt %s\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.name == flag.Arg(0) {
+			os.Exit(c.run(flag.Args()[1:]))
+		}
+	}
+	usage()
+	os.Exit(2)
+}
+
+func verify(args []string) int {
+	fs := newFlagSet("verify <index>")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	path := fs.Arg(0)
+	failed := false
+	for _, err := range gongt.Verify(path) {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		if _, ok := err.(*gongt.VerifyWarning); !ok {
+			failed = true
+		}
+	}
+	if failed {
+		return 1
+	}
+	fmt.Printf("%s: ok\n", path)
+	return 0
+}
+
+func info(args []string) int {
+	fs := newFlagSet("info <index>")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	n := gongt.OpenReadOnly(fs.Arg(0))
+	defer n.Close()
+	if errs := n.GetErrors(); len(errs) > 0 {
+		fmt.Fprintln(os.Stderr, errs)
+		return 1
+	}
+	s, err := n.Stats()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(w, "Dimension:\t%d\n", n.GetDim())
+	fmt.Fprintf(w, "Objects:\t%d\n", s.Objects)
+	fmt.Fprintf(w, "Removed objects:\t%d\n", s.RemovedObjects)
+	fmt.Fprintf(w, "Nodes:\t%d\n", s.Nodes)
+	fmt.Fprintf(w, "Edges:\t%d\n", s.Edges)
+	fmt.Fprintf(w, "Out-degree:\tmin %d, max %d, average %.2f\n", s.MinOutDegree, s.MaxOutDegree, s.AverageOutDegree)
+	fmt.Fprintf(w, "Unreachable nodes:\t%d\n", s.UnreachableNodes)
+	fmt.Fprintf(w, "Tree leaf nodes:\t%d\n", s.TreeLeafNodes)
+	names := make([]string, 0, len(s.FileSizes))
+	for name := range s.FileSizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "File %s:\t%d bytes\n", name, s.FileSizes[name])
+	}
+	if err = w.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func optimize(args []string) int {
+	fs := newFlagSet("optimize [-outgoing n] [-incoming n] [-prune] [-dataset file] <index> <output>")
+	outgoing := fs.Int("outgoing", gongt.DefaultOutgoingEdges, "number of outgoing edges each node keeps")
+	incoming := fs.Int("incoming", gongt.DefaultIncomingEdges, "number of edges added as reverse edges")
+	prune := fs.Bool("prune", false, "remove redundant paths")
+	ds := fs.String("dataset", "", "dataset file to compare recall before and after")
+	size := fs.Int("size", 10, "number of results for recall")
+	epsilon := fs.Float64("epsilon", gongt.DefaultEpsilon, "search epsilon for recall")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	n := gongt.OpenReadOnly(fs.Arg(0))
+	defer n.Close()
+	if errs := n.GetErrors(); len(errs) > 0 {
+		fmt.Fprintln(os.Stderr, errs)
+		return 1
+	}
+	err := n.Optimize(fs.Arg(1), gongt.OptimizeConfig{
+		OutgoingEdges: *outgoing,
+		IncomingEdges: *incoming,
+		PathPruning:   *prune,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *ds == "" {
+		return 0
+	}
+
+	queries, neighbors, err := loadTruth(*ds)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	o := gongt.OpenReadOnly(fs.Arg(1))
+	defer o.Close()
+	if errs := o.GetErrors(); len(errs) > 0 {
+		fmt.Fprintln(os.Stderr, errs)
+		return 1
+	}
+	for _, idx := range []struct {
+		name string
+		n    *gongt.NGT
+	}{{"before", n}, {"after", o}} {
+		e, err := idx.n.Evaluate(queries, neighbors, *size, *epsilon)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("%s:\trecall@%d %.4f\t%.1f qps\n", idx.name, *size, e.Recall, e.QPS())
+	}
+	return 0
+}
+
+// loadTruth reads test queries and neighbors converted to object ids.
+func loadTruth(path string) ([][]float64, [][]int, error) {
+	f, err := dataset.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	queries, err := f.Float64s(dataset.Test)
+	if err != nil {
+		return nil, nil, err
+	}
+	neighbors, err := f.Ints(dataset.Neighbors)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, ids := range neighbors {
+		for i := range ids {
+			ids[i]++
+		}
+	}
+	return queries, neighbors, nil
+}
+
+func export(args []string) int {
+	fs := newFlagSet("export [-format name] [-ids] <index> [output]")
+	name := fs.String("format", "ssv", "fvecs, bvecs, ivecs, npy, csv, tsv or ssv")
+	ids := fs.Bool("ids", false, "write object id as the first column")
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return 2
+	}
+	format, err := gongt.ParseExportFormat(*name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	n := gongt.OpenReadOnly(fs.Arg(0))
+	defer n.Close()
+	if errs := n.GetErrors(); len(errs) > 0 {
+		fmt.Fprintln(os.Stderr, errs)
+		return 1
+	}
+
+	w := os.Stdout
+	if fs.NArg() == 2 {
+		if w, err = os.Create(fs.Arg(1)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer w.Close()
+	}
+	if *ids {
+		err = n.ExportWithIDs(w, format)
+	} else {
+		err = n.Export(w, format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
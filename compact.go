@@ -0,0 +1,188 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/yahoojapan/gongt/internal/ngtfile"
+)
+
+type (
+	// CompactConfig is parameters for Compact
+	CompactConfig struct {
+		// PoolSize is passed to CreateIndex of the new index, DefaultPoolSize if 0
+		PoolSize int
+		// PreserveIDs keeps every object at its id. Removed ids are filled by
+		// placeholders removed after the graph is built, so the object repository
+		// keeps its holes but the graph is rebuilt from scratch.
+		PreserveIDs bool
+	}
+	// CompactResult is the outcome of Compact
+	CompactResult struct {
+		// Remap maps id in the source index to id in the compacted index
+		Remap map[int]int
+		// Objects is the number of objects copied
+		Objects int
+		// BytesBefore and BytesAfter are on-disk bytes of index files
+		BytesBefore int64
+		BytesAfter  int64
+	}
+)
+
+var distanceTypes = map[string]DistanceType{
+	"L1":      L1,
+	"L2":      L2,
+	"Angle":   Angle,
+	"Hamming": Hamming,
+	"Cosine":  Cosine,
+}
+
+// BytesSaved returns on-disk bytes reclaimed by Compact.
+func (r *CompactResult) BytesSaved() int64 {
+	return r.BytesBefore - r.BytesAfter
+}
+
+// Compact rebuilds NGT index from live objects into dst.
+func Compact(dst string, cfg CompactConfig) (*CompactResult, error) {
+	return Get().Compact(dst, cfg)
+}
+
+// Compact rebuilds NGT index from live objects into dst, which must be empty or not exist.
+// The source index is not modified; open dst to use the compacted index.
+func (n *NGT) Compact(dst string, cfg CompactConfig) (*CompactResult, error) {
+	res, err := n.compact(dst, cfg)
+	if err != nil {
+		n.errs = append(n.errs, err)
+		return nil, err
+	}
+	return res, nil
+}
+
+func (n *NGT) compact(dst string, cfg CompactConfig) (*CompactResult, error) {
+	if n.prop.IndexPath == "" {
+		return nil, ErrNoIndexPath
+	}
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = DefaultPoolSize
+	}
+	if infos, err := ioutil.ReadDir(dst); err == nil && len(infos) > 0 {
+		return nil, fmt.Errorf("compact: %s is not empty", dst)
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return nil, err
+	}
+
+	// settings the C API does not return are taken from the saved property file
+	n.smu.Lock()
+	prop, err := ngtfile.ReadProperty(n.prop.IndexPath)
+	if err != nil {
+		n.smu.Unlock()
+		return nil, err
+	}
+	before := indexBytes(n.prop.IndexPath)
+	n.smu.Unlock()
+
+	c := New(dst).SetObjectType(n.prop.ObjectType).SetDimension(n.prop.Dimension)
+	if dt, ok := distanceTypes[prop["DistanceType"]]; ok {
+		c.SetDistanceType(dt)
+	}
+	if size, err := strconv.Atoi(prop["EdgeSizeForCreation"]); err == nil {
+		c.SetCreationEdgeSize(size)
+	}
+	if size, err := strconv.Atoi(prop["EdgeSizeForSearch"]); err == nil {
+		c.SetSearchEdgeSize(size)
+	}
+	c.Open()
+	defer c.Close()
+	if errs := c.GetErrors(); len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	res := &CompactResult{
+		Remap:       make(map[int]int),
+		BytesBefore: before,
+	}
+	placeholders := make([]uint, 0)
+	vec := make([]float64, n.prop.Dimension)
+
+	n.mu.RLock()
+	n.rangeObjects(func(id uint, obj []float32) bool {
+		for i, v := range obj {
+			vec[i] = float64(v)
+		}
+		if cfg.PreserveIDs {
+			for c.nextID() < id {
+				var pid uint
+				if pid, err = c.insert(vec); err != nil {
+					return false
+				}
+				placeholders = append(placeholders, pid)
+			}
+		}
+		var nid uint
+		if nid, err = c.insert(vec); err != nil {
+			return false
+		}
+		res.Remap[int(id)] = int(nid)
+		res.Objects++
+		return true
+	})
+	n.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = c.createIndex(cfg.PoolSize); err != nil {
+		return nil, err
+	}
+	for _, id := range placeholders {
+		if err = c.remove(id); err != nil {
+			return nil, err
+		}
+	}
+	if err = c.saveTo(dst); err != nil {
+		return nil, err
+	}
+	res.BytesAfter = indexBytes(dst)
+	return res, nil
+}
+
+// nextID returns the id the next insert into a fresh index gets.
+func (n *NGT) nextID() uint {
+	if n.repoSize < 1 {
+		return 1
+	}
+	return n.repoSize
+}
+
+// indexBytes returns the total size of index files in dir.
+func indexBytes(dir string) int64 {
+	var size int64
+	for _, name := range ngtfile.Files {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
@@ -0,0 +1,138 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestCompact(t *testing.T) {
+	vectors := [][]float64{
+		{1, 0, 0, 0, 0, 0},
+		{0, 1, 0, 0, 0, 0},
+		{0, 0, 1, 0, 0, 0},
+		{0, 0, 0, 1, 0, 0},
+		{0, 0, 0, 0, 1, 0},
+		{1, 1, 0, 0, 0, 0},
+	}
+	tests := []struct {
+		preserve bool
+		want     map[int]int
+	}{
+		{false, map[int]int{1: 1, 3: 2, 4: 3, 6: 4}},
+		{true, map[int]int{1: 1, 3: 3, 4: 4, 6: 6}},
+	}
+
+	for _, tt := range tests {
+		tmpdir, err := ioutil.TempDir("", "tmpdir")
+		if err != nil {
+			t.Errorf("Unexpected error: TestCompact(%v)", err)
+		}
+		defer os.RemoveAll(tmpdir)
+
+		if err := exec.Command("cp", "-r", index, tmpdir).Run(); err != nil {
+			t.Errorf("Unexpected error: TestCompact(%v)", err)
+		}
+		ngt := New(path.Join(tmpdir, "index")).Open()
+		for _, id := range []int{2, 5} {
+			if err := ngt.Remove(id); err != nil {
+				t.Errorf("Unexpected error: TestCompact(%v)", err)
+			}
+		}
+		if err := ngt.CreateAndSaveIndex(poolSize); err != nil {
+			t.Errorf("Unexpected error: TestCompact(%v)", err)
+		}
+
+		dst := path.Join(tmpdir, "compact")
+		res, err := ngt.Compact(dst, CompactConfig{PoolSize: poolSize, PreserveIDs: tt.preserve})
+		ngt.Close()
+		if err != nil {
+			t.Errorf("Unexpected error: TestCompact(%v)", err)
+			continue
+		}
+		if !reflect.DeepEqual(res.Remap, tt.want) {
+			t.Errorf("TestCompact(%v): %v, wanted: %v", tt.preserve, res.Remap, tt.want)
+		}
+		if res.Objects != len(tt.want) {
+			t.Errorf("TestCompact(%v): %v objects, wanted: %v", tt.preserve, res.Objects, len(tt.want))
+		}
+
+		c := New(dst).Open()
+		if errs := c.GetErrors(); len(errs) > 0 {
+			t.Errorf("Unexpected error: TestCompact(%v)", errs)
+		}
+		if got := c.Len(); got != len(tt.want) {
+			t.Errorf("TestCompact(%v): length %v, wanted: %v", tt.preserve, got, len(tt.want))
+		}
+		for old, id := range tt.want {
+			vec, err := c.GetVector(id)
+			if err != nil {
+				t.Errorf("Unexpected error: TestCompact(%v)", err)
+			}
+			if !reflect.DeepEqual(vec, vectors[old-1]) {
+				t.Errorf("TestCompact(%v): %v, wanted: %v", old, vec, vectors[old-1])
+			}
+			result, err := c.Search(vectors[old-1], 1, DefaultEpsilon)
+			if err != nil {
+				t.Errorf("Unexpected error: TestCompact(%v)", err)
+			}
+			if len(result) == 0 || result[0].ID != id {
+				t.Errorf("TestCompact(%v): %v, wanted: %v", old, result, id)
+			}
+		}
+		c.Close()
+	}
+}
+
+func TestCompactNotEmpty(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestCompactNotEmpty(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	if err := ioutil.WriteFile(path.Join(tmpdir, "file"), nil, 0644); err != nil {
+		t.Errorf("Unexpected error: TestCompactNotEmpty(%v)", err)
+	}
+
+	ngt := New(index).Open()
+	defer ngt.Close()
+	if _, err := ngt.Compact(tmpdir, CompactConfig{}); err == nil {
+		t.Errorf("TestCompactNotEmpty: compacted into non-empty directory")
+	}
+}
+
+func TestCompactInMemory(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestCompactInMemory(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	ngt := OpenInMemory(Property{Dimension: 6})
+	defer ngt.Close()
+	if _, err := ngt.Compact(path.Join(tmpdir, "dst"), CompactConfig{}); err != ErrNoIndexPath {
+		t.Errorf("TestCompactInMemory: %v, wanted: %v", err, ErrNoIndexPath)
+	}
+}
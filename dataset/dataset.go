@@ -0,0 +1,396 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package dataset reads and writes ann-benchmarks datasets in a simple binary format,
+// so benchmarks and evaluation run without the HDF5 C library.
+//
+// A dataset file is laid out in little endian as
+//
+//	magic "GONGTDS1" | count uint32 | count * section header | section data
+//
+// where each section header is
+//
+//	name [16]byte | dtype uint32 | rows uint32 | cols uint32 | offset uint64
+//
+// name is NUL padded, offset is from the beginning of the file and
+// section data is rows * cols values of dtype in row-major order.
+//
+// ann-benchmarks files have sections train (float32), test (float32),
+// neighbors (int32) and distances (float32).
+// cmd/annconv converts ann-benchmarks HDF5 files into this format.
+package dataset
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+type (
+	// Dataset is train and test vectors with ground truth of test queries
+	Dataset struct {
+		Train     [][]float64
+		Test      [][]float64
+		Neighbors [][]int
+		Distances [][]float64
+	}
+
+	// Section is a matrix stored in dataset file
+	Section struct {
+		Name   string
+		Type   DType
+		Rows   int
+		Cols   int
+		offset int64
+	}
+
+	// File is an opened dataset file
+	File struct {
+		f        *os.File
+		sections []Section
+	}
+
+	// DType is element type of section
+	DType uint32
+)
+
+const (
+	// Float32 is little endian IEEE 754 float32
+	Float32 DType = iota + 1
+	// Int32 is little endian int32
+	Int32
+
+	// Train is the section name of vectors to index
+	Train = "train"
+	// Test is the section name of query vectors
+	Test = "test"
+	// Neighbors is the section name of ids of true nearest neighbors of each query
+	Neighbors = "neighbors"
+	// Distances is the section name of distances to true nearest neighbors of each query
+	Distances = "distances"
+
+	magic      = "GONGTDS1"
+	nameSize   = 16
+	headerSize = nameSize + 4 + 4 + 4 + 8
+)
+
+var (
+	// ErrBadMagic raises when file is not a dataset file
+	ErrBadMagic = errors.New("not a dataset file")
+	// ErrNoSection raises when requested section does not exist
+	ErrNoSection = errors.New("section not found")
+)
+
+// Size returns byte size of an element
+func (t DType) Size() int {
+	switch t {
+	case Float32, Int32:
+		return 4
+	}
+	return 0
+}
+
+// String returns dtype name
+func (t DType) String() string {
+	switch t {
+	case Float32:
+		return "float32"
+	case Int32:
+		return "int32"
+	}
+	return fmt.Sprintf("DType(%d)", uint32(t))
+}
+
+// Open opens dataset file and reads its section headers
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	sections, err := readHeader(bufio.NewReader(f), info.Size())
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &File{f: f, sections: sections}, nil
+}
+
+// Close closes dataset file
+func (f *File) Close() error {
+	return f.f.Close()
+}
+
+// Sections returns headers of all sections
+func (f *File) Sections() []Section {
+	return append([]Section(nil), f.sections...)
+}
+
+// Section returns header of named section
+func (f *File) Section(name string) (Section, error) {
+	for _, s := range f.sections {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return Section{}, fmt.Errorf("%s: %v", name, ErrNoSection)
+}
+
+// Float64s reads named section as float64 rows
+func (f *File) Float64s(name string) ([][]float64, error) {
+	s, err := f.Section(name)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]float64, s.Rows)
+	err = f.read(s, func(i int, buf []byte) {
+		row := make([]float64, s.Cols)
+		for j := range row {
+			bits := binary.LittleEndian.Uint32(buf[4*j:])
+			if s.Type == Int32 {
+				row[j] = float64(int32(bits))
+			} else {
+				row[j] = float64(math.Float32frombits(bits))
+			}
+		}
+		rows[i] = row
+	})
+	return rows, err
+}
+
+// Ints reads named section as int rows
+func (f *File) Ints(name string) ([][]int, error) {
+	s, err := f.Section(name)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]int, s.Rows)
+	err = f.read(s, func(i int, buf []byte) {
+		row := make([]int, s.Cols)
+		for j := range row {
+			bits := binary.LittleEndian.Uint32(buf[4*j:])
+			if s.Type == Int32 {
+				row[j] = int(int32(bits))
+			} else {
+				row[j] = int(math.Float32frombits(bits))
+			}
+		}
+		rows[i] = row
+	})
+	return rows, err
+}
+
+func (f *File) read(s Section, fn func(i int, buf []byte)) error {
+	r := bufio.NewReader(io.NewSectionReader(f.f, s.offset, int64(s.Rows*s.Cols*s.Type.Size())))
+	buf := make([]byte, s.Cols*s.Type.Size())
+	for i := 0; i < s.Rows; i++ {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return fmt.Errorf("%s: row %d: %v", s.Name, i, err)
+		}
+		fn(i, buf)
+	}
+	return nil
+}
+
+// Load reads every known section of dataset file.
+// Missing sections are left nil.
+func Load(path string) (*Dataset, error) {
+	f, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d := new(Dataset)
+	for _, s := range f.sections {
+		switch s.Name {
+		case Train:
+			d.Train, err = f.Float64s(s.Name)
+		case Test:
+			d.Test, err = f.Float64s(s.Name)
+		case Neighbors:
+			d.Neighbors, err = f.Ints(s.Name)
+		case Distances:
+			d.Distances, err = f.Float64s(s.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// Save writes dataset to path
+func Save(path string, d *Dataset) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = Write(f, d); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Write writes non-empty sections of dataset to w
+func Write(w io.Writer, d *Dataset) error {
+	sw := NewWriter(w)
+	if len(d.Train) > 0 {
+		sw.AddFloat32(Train, d.Train)
+	}
+	if len(d.Test) > 0 {
+		sw.AddFloat32(Test, d.Test)
+	}
+	if len(d.Neighbors) > 0 {
+		rows := make([][]float64, len(d.Neighbors))
+		for i, ids := range d.Neighbors {
+			rows[i] = make([]float64, len(ids))
+			for j, id := range ids {
+				rows[i][j] = float64(id)
+			}
+		}
+		sw.AddInt32(Neighbors, rows)
+	}
+	if len(d.Distances) > 0 {
+		sw.AddFloat32(Distances, d.Distances)
+	}
+	return sw.Flush()
+}
+
+// Writer collects sections and writes them as a dataset file on Flush
+type Writer struct {
+	w        io.Writer
+	sections []Section
+	data     [][][]float64
+}
+
+// NewWriter returns Writer writing to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// AddFloat32 adds section stored as float32
+func (sw *Writer) AddFloat32(name string, rows [][]float64) {
+	sw.add(name, Float32, rows)
+}
+
+// AddInt32 adds section stored as int32
+func (sw *Writer) AddInt32(name string, rows [][]float64) {
+	sw.add(name, Int32, rows)
+}
+
+func (sw *Writer) add(name string, t DType, rows [][]float64) {
+	cols := 0
+	if len(rows) > 0 {
+		cols = len(rows[0])
+	}
+	sw.sections = append(sw.sections, Section{Name: name, Type: t, Rows: len(rows), Cols: cols})
+	sw.data = append(sw.data, rows)
+}
+
+// Flush writes header and every added section
+func (sw *Writer) Flush() error {
+	offset := int64(len(magic) + 4 + headerSize*len(sw.sections))
+	for i := range sw.sections {
+		s := &sw.sections[i]
+		if len(s.Name) > nameSize || strings.IndexByte(s.Name, 0) >= 0 {
+			return fmt.Errorf("invalid section name %q", s.Name)
+		}
+		for j, row := range sw.data[i] {
+			if len(row) != s.Cols {
+				return fmt.Errorf("%s: row %d has dimension %d, wanted: %d", s.Name, j, len(row), s.Cols)
+			}
+		}
+		s.offset = offset
+		offset += int64(s.Rows * s.Cols * s.Type.Size())
+	}
+
+	w := bufio.NewWriter(sw.w)
+	w.WriteString(magic)
+	binary.Write(w, binary.LittleEndian, uint32(len(sw.sections)))
+	for _, s := range sw.sections {
+		name := make([]byte, nameSize)
+		copy(name, s.Name)
+		w.Write(name)
+		binary.Write(w, binary.LittleEndian, uint32(s.Type))
+		binary.Write(w, binary.LittleEndian, uint32(s.Rows))
+		binary.Write(w, binary.LittleEndian, uint32(s.Cols))
+		binary.Write(w, binary.LittleEndian, uint64(s.offset))
+	}
+	buf := make([]byte, 4)
+	for i, s := range sw.sections {
+		for _, row := range sw.data[i] {
+			for _, v := range row {
+				if s.Type == Int32 {
+					binary.LittleEndian.PutUint32(buf, uint32(int32(v)))
+				} else {
+					binary.LittleEndian.PutUint32(buf, math.Float32bits(float32(v)))
+				}
+				if _, err := w.Write(buf); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return w.Flush()
+}
+
+func readHeader(r io.Reader, size int64) ([]Section, error) {
+	buf := make([]byte, len(magic)+4)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, ErrBadMagic
+	}
+	if string(buf[:len(magic)]) != magic {
+		return nil, ErrBadMagic
+	}
+	count := int(binary.LittleEndian.Uint32(buf[len(magic):]))
+	if int64(count) > size/headerSize {
+		return nil, ErrBadMagic
+	}
+
+	sections := make([]Section, count)
+	hdr := make([]byte, headerSize)
+	for i := range sections {
+		if _, err := io.ReadFull(r, hdr); err != nil {
+			return nil, err
+		}
+		s := Section{
+			Name:   strings.TrimRight(string(hdr[:nameSize]), "\x00"),
+			Type:   DType(binary.LittleEndian.Uint32(hdr[nameSize:])),
+			Rows:   int(binary.LittleEndian.Uint32(hdr[nameSize+4:])),
+			Cols:   int(binary.LittleEndian.Uint32(hdr[nameSize+8:])),
+			offset: int64(binary.LittleEndian.Uint64(hdr[nameSize+12:])),
+		}
+		if s.Type.Size() == 0 {
+			return nil, fmt.Errorf("%s: unknown dtype %v", s.Name, s.Type)
+		}
+		if s.offset < 0 || s.offset+int64(s.Rows)*int64(s.Cols)*int64(s.Type.Size()) > size {
+			return nil, fmt.Errorf("%s: section exceeds file size", s.Name)
+		}
+		sections[i] = s
+	}
+	return sections, nil
+}
@@ -0,0 +1,120 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package dataset
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	want := &Dataset{
+		Train: [][]float64{
+			{1, 0, 0},
+			{0, 1, 0},
+			{0, 0, 1},
+			{0.5, 0.25, -1},
+		},
+		Test: [][]float64{
+			{1, 0.125, 0},
+		},
+		Neighbors: [][]int{
+			{0, 3},
+		},
+		Distances: [][]float64{
+			{0.125, 1.5},
+		},
+	}
+
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestSaveAndLoad(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	path := filepath.Join(tmpdir, "test.gds")
+	if err := Save(path, want); err != nil {
+		t.Errorf("Unexpected error: TestSaveAndLoad(%v)", err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Errorf("Unexpected error: TestSaveAndLoad(%v)", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TestSaveAndLoad: %v, wanted: %v", got, want)
+	}
+
+	f, err := Open(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: TestSaveAndLoad(%v)", err)
+	}
+	defer f.Close()
+	tests := []struct {
+		name string
+		want Section
+	}{
+		{Train, Section{Name: Train, Type: Float32, Rows: 4, Cols: 3}},
+		{Test, Section{Name: Test, Type: Float32, Rows: 1, Cols: 3}},
+		{Neighbors, Section{Name: Neighbors, Type: Int32, Rows: 1, Cols: 2}},
+		{Distances, Section{Name: Distances, Type: Float32, Rows: 1, Cols: 2}},
+	}
+	for _, tt := range tests {
+		s, err := f.Section(tt.name)
+		if err != nil {
+			t.Errorf("Unexpected error: TestSaveAndLoad(%v)", err)
+		}
+		s.offset = 0
+		if s != tt.want {
+			t.Errorf("TestSaveAndLoad(%v): %v, wanted: %v", tt.name, s, tt.want)
+		}
+	}
+	if _, err := f.Section("unknown"); err == nil {
+		t.Errorf("TestSaveAndLoad: missing section is found")
+	}
+}
+
+func TestOpenBrokenFile(t *testing.T) {
+	tests := []struct {
+		data []byte
+	}{
+		{[]byte{}},
+		{[]byte("GONGTDS0\x00\x00\x00\x00")},
+		// one section header pointing past the end of file
+		{append([]byte("GONGTDS1\x01\x00\x00\x00train\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"),
+			1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 48, 0, 0, 0, 0, 0, 0, 0)},
+	}
+
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestOpenBrokenFile(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	for i, tt := range tests {
+		path := filepath.Join(tmpdir, "broken.gds")
+		if err := ioutil.WriteFile(path, tt.data, 0644); err != nil {
+			t.Errorf("Unexpected error: TestOpenBrokenFile(%v)", err)
+		}
+		if f, err := Open(path); err == nil {
+			f.Close()
+			t.Errorf("TestOpenBrokenFile(%v): broken file is opened", i)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package gongt
+
+import (
+	"math"
+	"math/bits"
+)
+
+// toObject converts vec into object of type ot as NGT stores it,
+// values of Uint8 objects are truncated.
+func toObject(ot ObjectType, vec []float64) []float32 {
+	obj := make([]float32, len(vec))
+	for i, v := range vec {
+		if ot == Uint8 {
+			obj[i] = float32(uint8(v))
+		} else {
+			obj[i] = float32(v)
+		}
+	}
+	return obj
+}
+
+// distance returns distance between objects a and b as NGT computes it for dt,
+// infinity for distance types the C API does not support.
+func distance(dt DistanceType, a, b []float32) float32 {
+	switch dt {
+	case L1:
+		var sum float64
+		for i := range a {
+			sum += math.Abs(float64(a[i] - b[i]))
+		}
+		return float32(sum)
+	case L2:
+		var sum float64
+		for i := range a {
+			d := float64(a[i] - b[i])
+			sum += d * d
+		}
+		return float32(math.Sqrt(sum))
+	case Angle:
+		return float32(math.Acos(cosine(a, b)))
+	case Cosine:
+		return float32(1 - cosine(a, b))
+	case Hamming:
+		count := 0
+		for i := range a {
+			count += bits.OnesCount8(uint8(a[i]) ^ uint8(b[i]))
+		}
+		return float32(count)
+	}
+	return float32(math.Inf(1))
+}
+
+// cosine returns cosine similarity of a and b clamped to [-1, 1].
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return math.Max(-1, math.Min(1, dot/math.Sqrt(na*nb)))
+}
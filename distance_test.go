@@ -0,0 +1,42 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package gongt
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		dt   DistanceType
+		a, b []float32
+		want float64
+	}{
+		{L1, []float32{1, 2, 3}, []float32{3, 2, 0}, 5},
+		{L2, []float32{0, 3, 0}, []float32{4, 0, 0}, 5},
+		{Angle, []float32{1, 0}, []float32{0, 2}, math.Pi / 2},
+		{Cosine, []float32{1, 0}, []float32{-1, 0}, 2},
+		{Hamming, []float32{0xff, 1}, []float32{0x0f, 2}, 6},
+	}
+
+	for _, tt := range tests {
+		if got := distance(tt.dt, tt.a, tt.b); math.Abs(float64(got)-tt.want) > 1e-6 {
+			t.Errorf("TestDistance(%v, %v, %v): %v, wanted: %v", tt.dt, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
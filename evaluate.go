@@ -0,0 +1,90 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"errors"
+	"time"
+)
+
+// Evaluation is search quality of NGT index against ground truth
+type Evaluation struct {
+	Queries int
+	// Recall is the average fraction of true neighbors found in the results
+	Recall float64
+	// Elapsed is the total time spent in Search
+	Elapsed time.Duration
+}
+
+// QPS returns queries per second.
+func (e *Evaluation) QPS() float64 {
+	if e.Elapsed <= 0 {
+		return 0
+	}
+	return float64(e.Queries) / e.Elapsed.Seconds()
+}
+
+// Evaluate searches default NGT index with queries and compares results with neighbors.
+func Evaluate(queries [][]float64, neighbors [][]int, size int, epsilon float64) (*Evaluation, error) {
+	return Get().Evaluate(queries, neighbors, size, epsilon)
+}
+
+// Evaluate searches NGT index with queries and compares results with neighbors,
+// the ids of true nearest neighbors of each query. Only the first size neighbors count.
+// ann-benchmarks neighbors are 0-based rows of train, which are ids minus 1
+// when train is inserted in order into an empty index.
+func (n *NGT) Evaluate(queries [][]float64, neighbors [][]int, size int, epsilon float64) (*Evaluation, error) {
+	if len(queries) != len(neighbors) {
+		return nil, errors.New("the number of queries and neighbors differ")
+	}
+
+	e := &Evaluation{Queries: len(queries)}
+	sum := 0.0
+	for i, q := range queries {
+		start := time.Now()
+		result, err := n.Search(q, size, epsilon)
+		e.Elapsed += time.Since(start)
+		if err != nil {
+			return nil, err
+		}
+
+		truth := neighbors[i]
+		if len(truth) > size {
+			truth = truth[:size]
+		}
+		if len(truth) == 0 {
+			continue
+		}
+		want := make(map[int]bool, len(truth))
+		for _, id := range truth {
+			want[id] = true
+		}
+		found := 0
+		for _, r := range result {
+			if want[r.ID] {
+				found++
+			}
+		}
+		sum += float64(found) / float64(len(truth))
+	}
+	if e.Queries > 0 {
+		e.Recall = sum / float64(e.Queries)
+	}
+	return e, nil
+}
@@ -14,85 +14,65 @@
 // limitations under the License.
 //
 
+//go:build cgo && !purego
+
 package main
 
 import (
 	"flag"
+	"log/slog"
 	"os"
 	"runtime"
+	"time"
 
-	"github.com/kpango/glg"
 	"github.com/yahoojapan/gongt"
-	"gonum.org/v1/hdf5"
+	"github.com/yahoojapan/gongt/dataset"
 )
 
 func getVectors(path, key string) ([][]float64, error) {
-	f, err := hdf5.OpenFile(path, hdf5.F_ACC_RDONLY)
-	if err != nil {
-		return nil, err
-	}
-	dset, err := f.OpenDataset(key)
-	if err != nil {
-		return nil, err
-	}
-	space := dset.Space()
-	dims, _, err := space.SimpleExtentDims()
+	f, err := dataset.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	v := make([]float32, space.SimpleExtentNPoints())
-	if err := dset.Read(&v); err != nil {
-		return nil, err
-	}
-
-	row := int(dims[0])
-	col := int(dims[1])
-
-	vec := make([][]float64, row)
-	for i := 0; i < row; i++ {
-		vec[i] = make([]float64, col)
-		for j := 0; j < col; j++ {
-			vec[i][j] = float64(v[i*col+j])
-		}
-	}
-	return vec, nil
+	defer f.Close()
+	return f.Float64s(key)
 }
 
 func create(name, path string) {
 	if _, err := os.Stat(name); err == nil {
-		glg.Infof("[%s] %s exists", name, path)
+		slog.Info("index exists", "name", name, "dataset", path)
 		return
 	}
-	vectors, err := getVectors(path, "train")
+	vectors, err := getVectors(path, dataset.Train)
 	if err != nil {
-		glg.Warn(err)
+		slog.Warn("failed", "name", name, "error", err)
 		return
 	}
-	glg.Infof("[%s] %d items", name, len(vectors))
-	defer glg.Infof("[%s] done", name)
+	slog.Info("start", "name", name, "items", len(vectors))
+	defer slog.Info("done", "name", name)
 
-	n := gongt.New(name).SetObjectType(gongt.Float).SetDimension(len(vectors[0])).Open()
+	n := gongt.New(name).SetLogger(slog.Default()).SetObjectType(gongt.Float).SetDimension(len(vectors[0])).Open()
 	defer n.Close()
 
 	for _, v := range vectors {
 		n.Insert(v)
 	}
 	if err := n.CreateAndSaveIndex(runtime.NumCPU()); err != nil {
-		glg.Warn(err)
+		slog.Warn("failed", "name", name, "error", err)
 	}
 }
 
 func search(name, path string) {
-	n := gongt.New(name).Open()
+	n := gongt.New(name).SetLogger(slog.Default()).SetSlowQueryThreshold(10 * time.Millisecond).Open()
 	defer n.Close()
 
-	vectors, err := getVectors(path, "test")
+	vectors, err := getVectors(path, dataset.Test)
 	if err != nil {
-		glg.Warn(err)
+		slog.Warn("failed", "name", name, "error", err)
 		return
 	}
-	glg.Infof("[%s] %d items", name, len(vectors))
-	defer glg.Infof("[%s] done", name)
+	slog.Info("start", "name", name, "items", len(vectors))
+	defer slog.Info("done", "name", name)
 
 	for _, v := range vectors {
 		n.Search(v, 10, gongt.DefaultEpsilon)
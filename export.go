@@ -0,0 +1,266 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// ExportFormat is file format written by Export
+type ExportFormat int
+
+const (
+	// FormatNone is unknown format
+	FormatNone ExportFormat = iota
+	// Fvecs is int32 dimension followed by float32 values for each vector
+	Fvecs
+	// Bvecs is int32 dimension followed by uint8 values for each vector
+	Bvecs
+	// Ivecs is int32 dimension followed by int32 values for each vector
+	Ivecs
+	// Npy is NumPy .npy 2-dimensional array
+	Npy
+	// CSV is comma separated values
+	CSV
+	// TSV is tab separated values
+	TSV
+	// SSV is space separated values as assets/test/test.ssv
+	SSV
+)
+
+var (
+	// ErrExportIDs raises exporting ids to binary format
+	ErrExportIDs = errors.New("ids can be exported only to CSV, TSV and SSV")
+
+	formatNames = map[ExportFormat]string{
+		Fvecs: "fvecs",
+		Bvecs: "bvecs",
+		Ivecs: "ivecs",
+		Npy:   "npy",
+		CSV:   "csv",
+		TSV:   "tsv",
+		SSV:   "ssv",
+	}
+)
+
+// String returns name of format
+func (f ExportFormat) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return "none"
+}
+
+// ParseExportFormat returns ExportFormat named name
+func ParseExportFormat(name string) (ExportFormat, error) {
+	for f, n := range formatNames {
+		if n == name {
+			return f, nil
+		}
+	}
+	return FormatNone, fmt.Errorf("Unknown export format %q", name)
+}
+
+// Export writes every object in default NGT index to w
+func Export(w io.Writer, format ExportFormat) error {
+	return Get().Export(w, format)
+}
+
+// Export writes every object in NGT index to w in id order.
+// Removed objects are skipped and the read lock is held until writing ends.
+func (n *NGT) Export(w io.Writer, format ExportFormat) error {
+	return n.export(w, format, false)
+}
+
+// ExportWithIDs writes every object in default NGT index to w with its id
+func ExportWithIDs(w io.Writer, format ExportFormat) error {
+	return Get().ExportWithIDs(w, format)
+}
+
+// ExportWithIDs writes every object in NGT index to w with its id as the first column.
+// Only CSV, TSV and SSV are supported.
+func (n *NGT) ExportWithIDs(w io.Writer, format ExportFormat) error {
+	return n.export(w, format, true)
+}
+
+func (n *NGT) export(w io.Writer, format ExportFormat, withID bool) error {
+	n.mu.RLock()
+	err := n.writeObjects(w, format, withID)
+	n.mu.RUnlock()
+	if err != nil {
+		n.errs = append(n.errs, err)
+		return err
+	}
+	return nil
+}
+
+func (n *NGT) writeObjects(w io.Writer, format ExportFormat, withID bool) error {
+	var write func(bw *bufio.Writer, id uint, vec []float32) error
+	switch format {
+	case Fvecs:
+		write = writeFvecs
+	case Bvecs:
+		write = writeBvecs
+	case Ivecs:
+		write = writeIvecs
+	case Npy:
+		write = writeNpy
+	case CSV:
+		write = textWriter(',', withID)
+	case TSV:
+		write = textWriter('\t', withID)
+	case SSV:
+		write = textWriter(' ', withID)
+	default:
+		return fmt.Errorf("Unknown export format %v", format)
+	}
+	if withID && format < CSV {
+		return ErrExportIDs
+	}
+
+	bw := bufio.NewWriter(w)
+	if format == Npy {
+		rows := 0
+		n.rangeObjects(func(uint, []float32) bool {
+			rows++
+			return true
+		})
+		if err := writeNpyHeader(bw, n.prop.ObjectType, rows, n.prop.Dimension); err != nil {
+			return err
+		}
+		if n.prop.ObjectType == Uint8 {
+			write = writeNpyUint8
+		}
+	}
+
+	var err error
+	n.rangeObjects(func(id uint, vec []float32) bool {
+		err = write(bw, id, vec)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func writeDimension(bw *bufio.Writer, dim int) error {
+	return binary.Write(bw, binary.LittleEndian, int32(dim))
+}
+
+func writeFvecs(bw *bufio.Writer, _ uint, vec []float32) error {
+	if err := writeDimension(bw, len(vec)); err != nil {
+		return err
+	}
+	return binary.Write(bw, binary.LittleEndian, vec)
+}
+
+func writeBvecs(bw *bufio.Writer, id uint, vec []float32) error {
+	if err := writeDimension(bw, len(vec)); err != nil {
+		return err
+	}
+	for _, v := range vec {
+		if v < 0 || v > math.MaxUint8 || v != float32(math.Trunc(float64(v))) {
+			return fmt.Errorf("object %d: %v cannot be written as uint8", id, v)
+		}
+		if err := bw.WriteByte(uint8(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeIvecs(bw *bufio.Writer, id uint, vec []float32) error {
+	if err := writeDimension(bw, len(vec)); err != nil {
+		return err
+	}
+	buf := make([]int32, len(vec))
+	for i, v := range vec {
+		if v < math.MinInt32 || v > math.MaxInt32 || v != float32(math.Trunc(float64(v))) {
+			return fmt.Errorf("object %d: %v cannot be written as int32", id, v)
+		}
+		buf[i] = int32(v)
+	}
+	return binary.Write(bw, binary.LittleEndian, buf)
+}
+
+// writeNpyHeader writes .npy format version 1.0 header
+func writeNpyHeader(bw *bufio.Writer, ot ObjectType, rows, cols int) error {
+	descr := "<f4"
+	if ot == Uint8 {
+		descr = "|u1"
+	}
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%d, %d), }", descr, rows, cols)
+	// magic, version and header length take 10 bytes, total must be aligned to 64 bytes
+	pad := 64 - (10+len(header)+1)%64
+	if pad == 64 {
+		pad = 0
+	}
+	for i := 0; i < pad; i++ {
+		header += " "
+	}
+	header += "\n"
+
+	if _, err := bw.WriteString("\x93NUMPY\x01\x00"); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.LittleEndian, uint16(len(header))); err != nil {
+		return err
+	}
+	_, err := bw.WriteString(header)
+	return err
+}
+
+func writeNpy(bw *bufio.Writer, _ uint, vec []float32) error {
+	return binary.Write(bw, binary.LittleEndian, vec)
+}
+
+func writeNpyUint8(bw *bufio.Writer, _ uint, vec []float32) error {
+	for _, v := range vec {
+		if err := bw.WriteByte(uint8(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func textWriter(sep byte, withID bool) func(*bufio.Writer, uint, []float32) error {
+	return func(bw *bufio.Writer, id uint, vec []float32) error {
+		buf := make([]byte, 0, 16*(len(vec)+1))
+		if withID {
+			buf = strconv.AppendUint(buf, uint64(id), 10)
+			buf = append(buf, sep)
+		}
+		for i, v := range vec {
+			if i > 0 {
+				buf = append(buf, sep)
+			}
+			buf = strconv.AppendFloat(buf, float64(v), 'g', -1, 32)
+		}
+		buf = append(buf, '\n')
+		_, err := bw.Write(buf)
+		return err
+	}
+}
@@ -0,0 +1,77 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	ssv, err := ioutil.ReadFile("./assets/test/test.ssv")
+	if err != nil {
+		t.Fatalf("Unexpected error: TestExport(%v)", err)
+	}
+	tests := []struct {
+		format ExportFormat
+		withID bool
+		want   []byte
+	}{
+		{SSV, false, ssv},
+		{CSV, true, []byte("1,1,0,0,0,0,0\n2,0,1,0,0,0,0\n3,0,0,1,0,0,0\n4,0,0,0,1,0,0\n5,0,0,0,0,1,0\n6,1,1,0,0,0,0\n")},
+		{Bvecs, false, []byte{
+			6, 0, 0, 0, 1, 0, 0, 0, 0, 0,
+			6, 0, 0, 0, 0, 1, 0, 0, 0, 0,
+			6, 0, 0, 0, 0, 0, 1, 0, 0, 0,
+			6, 0, 0, 0, 0, 0, 0, 1, 0, 0,
+			6, 0, 0, 0, 0, 0, 0, 0, 1, 0,
+			6, 0, 0, 0, 1, 1, 0, 0, 0, 0,
+		}},
+	}
+
+	ngt := New(index).Open()
+	defer ngt.Close()
+	for _, tt := range tests {
+		buf := new(bytes.Buffer)
+		if tt.withID {
+			err = ngt.ExportWithIDs(buf, tt.format)
+		} else {
+			err = ngt.Export(buf, tt.format)
+		}
+		if err != nil {
+			t.Errorf("Unexpected error: TestExport(%v)", err)
+		}
+		if !bytes.Equal(buf.Bytes(), tt.want) {
+			t.Errorf("TestExport(%v): %q, wanted: %q", tt.format, buf.Bytes(), tt.want)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := ngt.Export(buf, Npy); err != nil {
+		t.Errorf("Unexpected error: TestExport(%v)", err)
+	}
+	// 128 bytes header and 6x6 uint8 array
+	if buf.Len() != 128+6*6 || !bytes.HasPrefix(buf.Bytes(), []byte("\x93NUMPY")) {
+		t.Errorf("TestExport(%v): unexpected size %v", Npy, buf.Len())
+	}
+	if err := ngt.ExportWithIDs(buf, Fvecs); err != ErrExportIDs {
+		t.Errorf("TestExport(%v): %v, wanted: %v", Fvecs, err, ErrExportIDs)
+	}
+}
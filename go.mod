@@ -1,8 +1,4 @@
 module github.com/yahoojapan/gongt
 
-go 1.12
+go 1.21
 
-require (
-	github.com/kpango/glg v1.4.1
-	gonum.org/v1/hdf5 v0.0.0-20190227001252-83207889d689
-)
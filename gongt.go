@@ -14,21 +14,31 @@
 // limitations under the License.
 //
 
-// Package gongt provides implementation of Go API for https://github.com/yahoojapan/NGT
+//go:build cgo && !purego
+
 package gongt
 
 /*
 #cgo LDFLAGS: -lngt
+#include <stdlib.h>
 #include <NGT/Capi.h>
 */
 import "C"
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
+
+	"github.com/yahoojapan/gongt/internal/ngtfile"
 )
 
 type (
@@ -38,113 +48,71 @@ type (
 		Distance float32
 		Error    error
 	}
-	// SearchResult is struct for comfortable use in Go
-	SearchResult struct {
-		ID       int
-		Distance float64
-	}
 	// NGT is gongt base struct
 	NGT struct {
 		prop   Property
 		index  C.NGTIndex
 		ospace C.NGTObjectSpace
 		mu     *sync.RWMutex
+		smu    *sync.Mutex
 		errs   []error
-	}
-	// Property includes parameters for NGT
-	Property struct {
-		Dimension           int
-		CreationEdgeSize    int
-		SearchEdgeSize      int
-		ObjectType          ObjectType
-		DistanceType        DistanceType
-		IndexPath           string
-		BulkInsertChunkSize int
+		wal    *wal
+		// repoSize is upper bound of object ids
+		repoSize uint
+		gcache   *graphCache
+		readOnly bool
+		staged   *staging
+		// cache is read without lock, SetSearchCache may replace it during searches
+		cache   *atomic.Pointer[searchCache]
+		metrics Metrics
+		logger  *slog.Logger
+		tracer  Tracer
+		// objects is the number of live objects, counted only for metrics
+		objects int
 	}
 )
 
-// ObjectType is alias of object type in NGT
-type ObjectType int
-
-// DistanceType is alias of distance type in NGT
-type DistanceType int
-
-const (
-	// ObjectNone is unknown object type
-	ObjectNone ObjectType = iota
-	// Uint8 is 8bit unsigned integer
-	Uint8
-	// Float is 32bit floating point number
-	Float
-
-	// DistanceNone is unknown distance type
-	DistanceNone DistanceType = iota - 1
-	// L1 is l1 norm
-	L1
-	// L2 is l2 norm
-	L2
-	// Angle is angle distance
-	Angle
-	// Hamming is hamming distance
-	Hamming
-	// Cosine is cosine distance
-	Cosine
-	// NormalizedAngle is angle distance with normalization
-	NormalizedAngle
-	// NormalizedCosine is cosine distance with normalization
-	NormalizedCosine
-
-	// DefaultDimension is 0
-	DefaultDimension = 0
-	// DefaultCreationEdgeSize is 10
-	DefaultCreationEdgeSize = 10
-	// DefaultSearchEdgeSize is 10
-	DefaultSearchEdgeSize = 40
-	// DefaultObjectType is Float
-	DefaultObjectType = Float
-	// DefaultDistanceType is L2
-	DefaultDistanceType = L2
-	// DefaultEpsilon is 0.01
-	DefaultEpsilon = 0.01
-	// DefaultBulkInsertChunkSize is 100
-	DefaultBulkInsertChunkSize = 100
-	// DefaultPoolSize is 1
-	DefaultPoolSize = 1
-
-	// ErrorCode is false
-	ErrorCode = C._Bool(false)
-)
+// ErrorCode is false
+const ErrorCode = C._Bool(false)
 
-var (
-	once = &sync.Once{}
-	ngt  *NGT
+// NGT implements Index
+var _ Index = (*NGT)(nil)
 
-	// ErrCAPINotImplemented raises using not implemented function in C API
-	ErrCAPINotImplemented = errors.New("Not implemented in C API")
+var (
+	// ErrNoIndexPath raises opening NGT index without index path
+	ErrNoIndexPath = errors.New("index path is not set")
+	// ErrReadOnly raises modifying NGT index opened by OpenReadOnly
+	ErrReadOnly = errors.New("index is opened read-only")
 )
 
-func init() {
-	Get()
-}
-
 func newGoError(err C.NGTError) error {
 	return errors.New(C.GoString(C.ngt_get_error_string(err)))
 }
 
-// Get returns singleton instance NGT
-//	ngt := gongt.Get()
+// cError returns the error in ebuf and logs it.
+func (n *NGT) cError(ebuf C.NGTError) error {
+	err := newGoError(ebuf)
+	n.log(slog.LevelError, "NGT error", "error", err)
+	return err
+}
+
+// Get returns the default NGT instance package-level functions operate on.
+// It is registered to DefaultRegistry as DefaultName on first use and
+// has no index path, so set one before Open.
+//	ngt := gongt.Get().SetIndexPath("index Path").Open()
 func Get() *NGT {
-	once.Do(func() {
-		ngt = New("/tmp/ngt-" + time.Now().Format(time.RFC3339))
+	return DefaultRegistry.lookupOrRegister(DefaultName, func() *NGT {
+		return New("")
 	})
-	return ngt
 }
 
 // New returns NGT instance
 //	ngt := gongt.New("index Path")
 func New(indexPath string) *NGT {
 	return &NGT{
-		mu: &sync.RWMutex{},
+		mu:    &sync.RWMutex{},
+		smu:   &sync.Mutex{},
+		cache: &atomic.Pointer[searchCache]{},
 		prop: Property{
 			BulkInsertChunkSize: DefaultBulkInsertChunkSize,
 			CreationEdgeSize:    DefaultCreationEdgeSize,
@@ -160,7 +128,7 @@ func New(indexPath string) *NGT {
 // GetDim returns NGT dimension
 //	dimension := gongt.GetDim()
 func GetDim() int {
-	return ngt.GetDim()
+	return Get().GetDim()
 }
 
 // GetDim returns NGT dimension
@@ -173,7 +141,7 @@ func (n NGT) GetDim() int {
 // GetPath returns path to index directory
 //	indexPath := gongt.GetPath()
 func GetPath() string {
-	return ngt.GetPath()
+	return Get().GetPath()
 }
 
 // GetPath returns path to index directory
@@ -186,7 +154,7 @@ func (n NGT) GetPath() string {
 // SetIndexPath sets path to index directory
 //	gongt.SetIndexPath("index Path")
 func SetIndexPath(path string) *NGT {
-	return ngt.SetIndexPath(path)
+	return Get().SetIndexPath(path)
 }
 
 // SetIndexPath sets path to index directory
@@ -204,7 +172,7 @@ func (n *NGT) SetIndexPath(path string) *NGT {
 // SetDimension sets NGT feature dimension
 //	gongt.SetDimension(10) // Dimension Setting
 func SetDimension(dimension int) *NGT {
-	return ngt.SetDimension(dimension)
+	return Get().SetDimension(dimension)
 }
 
 // SetDimension sets NGT feature dimension
@@ -222,7 +190,7 @@ func (n *NGT) SetDimension(dimension int) *NGT {
 // SetCreationEdgeSize sets creation edge size
 //	gongt.SetCreationEdgeSize(10) // CreationEdgeSize Setting
 func SetCreationEdgeSize(size int) *NGT {
-	return ngt.SetCreationEdgeSize(size)
+	return Get().SetCreationEdgeSize(size)
 }
 
 // SetCreationEdgeSize sets creation edge size
@@ -240,7 +208,7 @@ func (n *NGT) SetCreationEdgeSize(size int) *NGT {
 // SetSearchEdgeSize sets search edge size
 //	gongt.SetSearchEdgeSize(10) // SearchEdgeSize Setting
 func SetSearchEdgeSize(size int) *NGT {
-	return ngt.SetSearchEdgeSize(size)
+	return Get().SetSearchEdgeSize(size)
 }
 
 // SetSearchEdgeSize sets search edge size
@@ -260,7 +228,7 @@ func (n *NGT) SetSearchEdgeSize(size int) *NGT {
 //	gongt.SetObjectType(gongt.Uint8) // ObjectType Setting
 //	gongt.SetObjectType(gongt.ObjectNone) // ObjectType Setting
 func SetObjectType(ot ObjectType) *NGT {
-	return ngt.SetObjectType(ot)
+	return Get().SetObjectType(ot)
 }
 
 // SetObjectType sets object type
@@ -283,7 +251,7 @@ func (n *NGT) SetObjectType(ot ObjectType) *NGT {
 //	gongt.SetDistanceType(gongt.L2) // DistanceType Setting
 //	gongt.SetDistanceType(gongt.Hamming) // DistanceType Setting
 func SetDistanceType(dt DistanceType) *NGT {
-	return ngt.SetDistanceType(dt)
+	return Get().SetDistanceType(dt)
 }
 
 // SetDistanceType sets distance type
@@ -302,7 +270,7 @@ func (n *NGT) SetDistanceType(dt DistanceType) *NGT {
 
 // SetBulkInsertChunkSize sets insert chunk size
 func SetBulkInsertChunkSize(size int) *NGT {
-	return ngt.SetBulkInsertChunkSize(size)
+	return Get().SetBulkInsertChunkSize(size)
 }
 
 // SetBulkInsertChunkSize sets insert chunk size
@@ -314,162 +282,395 @@ func (n *NGT) SetBulkInsertChunkSize(size int) *NGT {
 	return n
 }
 
+// SetWriteAheadLog enables write-ahead log replayed by Open
+func SetWriteAheadLog(enabled bool) *NGT {
+	return Get().SetWriteAheadLog(enabled)
+}
+
+// SetWriteAheadLog enables write-ahead log replayed by Open.
+// Insert, Remove and CreateIndex are recorded in the index directory and
+// the log is truncated after each successful SaveIndex.
+// Every record is synced to disk before the call returns, so mutations survive power loss
+// at the cost of one fsync each.
+func (n *NGT) SetWriteAheadLog(enabled bool) *NGT {
+	n.mu.Lock()
+	n.prop.WriteAheadLog = enabled
+	n.mu.Unlock()
+
+	return n
+}
+
+// SetKeepPreviousIndex keeps the previous generation on SaveIndex
+func SetKeepPreviousIndex(keep bool) *NGT {
+	return Get().SetKeepPreviousIndex(keep)
+}
+
+// SetKeepPreviousIndex keeps the previous generation on SaveIndex.
+// It is moved to IndexPath with PreviousIndexSuffix instead of being removed.
+func (n *NGT) SetKeepPreviousIndex(keep bool) *NGT {
+	n.mu.Lock()
+	n.prop.KeepPreviousIndex = keep
+	n.mu.Unlock()
+
+	return n
+}
+
 // Open configures using Property and returns NGT instance
 func Open() *NGT {
-	return ngt.Open()
+	return Get().Open()
 }
 
 // Open configures using Property and returns NGT instance
 func (n *NGT) Open() *NGT {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	defer n.observeErrors(OpOpen, time.Now(), len(n.errs))
+
+	if n.prop.IndexPath == "" {
+		n.errs = append(n.errs, ErrNoIndexPath)
+		return n
+	}
+	n.readOnly = false
+
+	ebuf := C.ngt_create_error_object()
+	defer C.ngt_destroy_error_object(ebuf)
+
+	prop, err := n.newProperty(ebuf)
+	if err != nil {
+		n.errs = append(n.errs, err)
+		return n
+	}
+	defer C.ngt_destroy_property(prop)
+
+	if err := recoverIndex(n.prop.IndexPath); err != nil {
+		n.errs = append(n.errs, err)
+		return n
+	}
+
+	n.index = C.ngt_open_index(C.CString(n.prop.IndexPath), ebuf)
+	if n.index == nil {
+		err := newGoError(ebuf)
+		if strings.Contains(err.Error(), "PropertySet::load: Cannot load the property file ") || strings.Contains(err.Error(), "PropertSet::load: Cannot load the property file ") {
+			n.index = C.ngt_create_graph_and_tree(C.CString(n.prop.IndexPath), prop, ebuf)
+			if n.index == nil {
+				n.errs = append(n.errs, n.cError(ebuf))
+				return n
+			}
+			if err := n.saveTo(n.prop.IndexPath); err != nil {
+				n.errs = append(n.errs, err)
+				return n
+			}
+			n.log(slog.LevelInfo, "created new index", "reason", "property file missing", "dimension", n.prop.Dimension)
+		} else {
+			n.errs = append(n.errs, n.cError(ebuf))
+			return n
+		}
+	}
+	n.invalidateCache()
+
+	if err := n.loadProperty(prop, ebuf); err != nil {
+		n.errs = append(n.errs, err)
+		return n
+	}
+
+	// C API cannot tell the repository size, upper bound of ids is taken from
+	// the saved object repository and grown by insert
+	size, _ := ngtfile.ReadRepositorySize(filepath.Join(n.prop.IndexPath, ngtfile.ObjectFile))
+	n.repoSize = uint(size)
+
+	// staged objects are indexed after Open releases the lock
+	n.openStaging()
+	if n.prop.WriteAheadLog {
+		if err := n.openWAL(); err != nil {
+			n.errs = append(n.errs, err)
+			return n
+		}
+	}
+	n.countObjects()
+	n.log(slog.LevelInfo, "opened index", "dimension", n.prop.Dimension, "wal", n.wal != nil, "staging", n.staged != nil)
+
+	return n
+}
+
+// OpenInMemory creates NGT index configured by prop without any filesystem path.
+// Zero values of prop take the defaults of New, IndexPath is ignored. Use SaveTo to persist it.
+//	ngt := gongt.OpenInMemory(gongt.Property{Dimension: 128})
+func OpenInMemory(prop Property) *NGT {
+	n := New("").
+		SetDimension(prop.Dimension).
+		SetKeepPreviousIndex(prop.KeepPreviousIndex).
+		SetWriteAheadLog(prop.WriteAheadLog).
+		SetStaging(prop.StagingSize, prop.StagingInterval).
+		SetSlowQueryThreshold(prop.SlowQueryThreshold)
+	if prop.CreationEdgeSize > 0 {
+		n.SetCreationEdgeSize(prop.CreationEdgeSize)
+	}
+	if prop.SearchEdgeSize > 0 {
+		n.SetSearchEdgeSize(prop.SearchEdgeSize)
+	}
+	if prop.ObjectType != ObjectNone {
+		n.SetObjectType(prop.ObjectType)
+	}
+	// the zero value is not a distance type, L1 is DistanceNone + 1
+	if prop.DistanceType != 0 {
+		n.SetDistanceType(prop.DistanceType)
+	}
+	if prop.BulkInsertChunkSize > 0 {
+		n.SetBulkInsertChunkSize(prop.BulkInsertChunkSize)
+	}
+	return n.OpenInMemory()
+}
+
+// OpenInMemory creates NGT index without any filesystem path, ignoring IndexPath.
+// Write-ahead log needs index directory, so it fails with ErrNoIndexPath if the log is enabled.
+//	ngt := gongt.New("").SetDimension(128).OpenInMemory()
+func (n *NGT) OpenInMemory() *NGT {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	defer n.observeErrors(OpOpen, time.Now(), len(n.errs))
+
+	if n.prop.WriteAheadLog {
+		n.errs = append(n.errs, ErrNoIndexPath)
+		return n
+	}
+	n.readOnly = false
+
+	ebuf := C.ngt_create_error_object()
+	defer C.ngt_destroy_error_object(ebuf)
+
+	prop, err := n.newProperty(ebuf)
+	if err != nil {
+		n.errs = append(n.errs, err)
+		return n
+	}
+	defer C.ngt_destroy_property(prop)
+
+	n.prop.IndexPath = ""
+	n.index = C.ngt_create_graph_and_tree_in_memory(prop, ebuf)
+	if n.index == nil {
+		n.errs = append(n.errs, n.cError(ebuf))
+		return n
+	}
+	n.invalidateCache()
+	if err := n.loadProperty(prop, ebuf); err != nil {
+		n.errs = append(n.errs, err)
+		return n
+	}
+	n.repoSize = 0
+	n.openStaging()
+	n.countObjects()
+	n.log(slog.LevelInfo, "created in-memory index", "dimension", n.prop.Dimension)
+	return n
+}
+
+// OpenReadOnly opens existing NGT index at path for search only.
+func OpenReadOnly(path string) *NGT {
+	return New(path).OpenReadOnly()
+}
+
+// OpenReadOnly opens existing NGT index at IndexPath for search only.
+// It fails if the index does not exist and never writes to IndexPath, so many processes
+// can open the same directory. Insert, Remove, CreateIndex and SaveIndex return ErrReadOnly.
+// Searches take no lock, so Close must not be called while searching.
+//	ngt := gongt.New("index Path").OpenReadOnly()
+func (n *NGT) OpenReadOnly() *NGT {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	defer n.observeErrors(OpOpen, time.Now(), len(n.errs))
+
+	// modifications are refused even if opening fails
+	n.readOnly = true
+	if n.prop.IndexPath == "" {
+		n.errs = append(n.errs, ErrNoIndexPath)
+		return n
+	}
+	if _, err := os.Stat(filepath.Join(n.prop.IndexPath, ngtfile.PropertyFile)); err != nil {
+		n.errs = append(n.errs, err)
+		return n
+	}
 
 	ebuf := C.ngt_create_error_object()
 	defer C.ngt_destroy_error_object(ebuf)
 
 	prop := C.ngt_create_property(ebuf)
 	if prop == nil {
-		n.errs = append(n.errs, newGoError(ebuf))
+		n.errs = append(n.errs, n.cError(ebuf))
 		return n
 	}
 	defer C.ngt_destroy_property(prop)
-	if C.ngt_set_property_dimension(prop, C.int32_t(n.prop.Dimension), ebuf) == ErrorCode {
-		n.errs = append(n.errs, newGoError(ebuf))
+
+	path := C.CString(n.prop.IndexPath)
+	defer C.free(unsafe.Pointer(path))
+	n.index = C.ngt_open_index(path, ebuf)
+	if n.index == nil {
+		n.errs = append(n.errs, n.cError(ebuf))
 		return n
 	}
-	if C.ngt_set_property_edge_size_for_creation(prop, C.int16_t(n.prop.CreationEdgeSize), ebuf) == ErrorCode {
-		n.errs = append(n.errs, newGoError(ebuf))
+	n.invalidateCache()
+	if err := n.loadProperty(prop, ebuf); err != nil {
+		C.ngt_close_index(n.index)
+		n.index = nil
+		n.errs = append(n.errs, err)
 		return n
 	}
+	size, _ := ngtfile.ReadRepositorySize(filepath.Join(n.prop.IndexPath, ngtfile.ObjectFile))
+	n.repoSize = uint(size)
+	n.countObjects()
+	n.log(slog.LevelInfo, "opened index", "dimension", n.prop.Dimension, "read_only", true)
+	return n
+}
+
+// newProperty creates NGT property from Property, caller must destroy it.
+func (n *NGT) newProperty(ebuf C.NGTError) (C.NGTProperty, error) {
+	prop := C.ngt_create_property(ebuf)
+	if prop == nil {
+		return nil, n.cError(ebuf)
+	}
+	err := n.setProperty(prop, ebuf)
+	if err != nil {
+		C.ngt_destroy_property(prop)
+		return nil, err
+	}
+	return prop, nil
+}
+
+func (n *NGT) setProperty(prop C.NGTProperty, ebuf C.NGTError) error {
+	if C.ngt_set_property_dimension(prop, C.int32_t(n.prop.Dimension), ebuf) == ErrorCode {
+		return n.cError(ebuf)
+	}
+	if C.ngt_set_property_edge_size_for_creation(prop, C.int16_t(n.prop.CreationEdgeSize), ebuf) == ErrorCode {
+		return n.cError(ebuf)
+	}
 	if C.ngt_set_property_edge_size_for_search(prop, C.int16_t(n.prop.SearchEdgeSize), ebuf) == ErrorCode {
-		n.errs = append(n.errs, newGoError(ebuf))
-		return n
+		return n.cError(ebuf)
 	}
 
 	switch n.prop.ObjectType {
 	case Uint8:
 		if C.ngt_set_property_object_type_integer(prop, ebuf) == ErrorCode {
-			n.errs = append(n.errs, newGoError(ebuf))
-			return n
+			return n.cError(ebuf)
 		}
 	case Float:
 		if C.ngt_set_property_object_type_float(prop, ebuf) == ErrorCode {
-			n.errs = append(n.errs, newGoError(ebuf))
-			return n
+			return n.cError(ebuf)
 		}
 	default:
-		n.errs = append(n.errs, errors.New("Illegal object type"))
-		return n
+		return errors.New("Illegal object type")
 	}
 
 	switch n.prop.DistanceType {
 	case L1:
 		if C.ngt_set_property_distance_type_l1(prop, ebuf) == ErrorCode {
-			n.errs = append(n.errs, newGoError(ebuf))
-			return n
+			return n.cError(ebuf)
 		}
 	case L2:
 		if C.ngt_set_property_distance_type_l2(prop, ebuf) == ErrorCode {
-			n.errs = append(n.errs, newGoError(ebuf))
-			return n
+			return n.cError(ebuf)
 		}
 	case Angle:
 		if C.ngt_set_property_distance_type_angle(prop, ebuf) == ErrorCode {
-			n.errs = append(n.errs, newGoError(ebuf))
-			return n
+			return n.cError(ebuf)
 		}
 	case Hamming:
 		if C.ngt_set_property_distance_type_hamming(prop, ebuf) == ErrorCode {
-			n.errs = append(n.errs, newGoError(ebuf))
-			return n
+			return n.cError(ebuf)
 		}
 	case Cosine:
 		if C.ngt_set_property_distance_type_cosine(prop, ebuf) == ErrorCode {
-			n.errs = append(n.errs, newGoError(ebuf))
-			return n
+			return n.cError(ebuf)
 		}
 	case NormalizedAngle:
 		// TODO: not implemented in C API
-		n.errs = append(n.errs, ErrCAPINotImplemented)
-		return n
+		return ErrCAPINotImplemented
 	case NormalizedCosine:
 		// TODO: not implemented in C API
-		n.errs = append(n.errs, ErrCAPINotImplemented)
-		return n
+		return ErrCAPINotImplemented
 	default:
-		n.errs = append(n.errs, errors.New("Illegal distance type"))
-		return n
-	}
-
-	n.index = C.ngt_open_index(C.CString(n.prop.IndexPath), ebuf)
-	if n.index == nil {
-		err := newGoError(ebuf)
-		if strings.Contains(err.Error(), "PropertySet::load: Cannot load the property file ") || strings.Contains(err.Error(), "PropertSet::load: Cannot load the property file ") {
-			n.index = C.ngt_create_graph_and_tree(C.CString(n.prop.IndexPath), prop, ebuf)
-			if n.index == nil {
-				n.errs = append(n.errs, newGoError(ebuf))
-				return n
-			}
-			if C.ngt_save_index(n.index, C.CString(n.prop.IndexPath), ebuf) == ErrorCode {
-				n.errs = append(n.errs, newGoError(ebuf))
-				return n
-			}
-		} else {
-			n.errs = append(n.errs, newGoError(ebuf))
-			return n
-		}
+		return errors.New("Illegal distance type")
 	}
+	return nil
+}
 
+// loadProperty reads back dimension and object type of opened index and its object space.
+func (n *NGT) loadProperty(prop C.NGTProperty, ebuf C.NGTError) error {
 	if C.ngt_get_property(n.index, prop, ebuf) == ErrorCode {
-		n.errs = append(n.errs, newGoError(ebuf))
-		return n
+		return n.cError(ebuf)
 	}
 	n.prop.Dimension = int(C.ngt_get_property_dimension(prop, ebuf))
 	if n.prop.Dimension == -1 {
-		n.errs = append(n.errs, newGoError(ebuf))
-		return n
+		return n.cError(ebuf)
 	}
 	n.prop.ObjectType = ObjectType(C.ngt_get_property_object_type(prop, ebuf))
 	if n.prop.ObjectType == -1 {
-		n.errs = append(n.errs, newGoError(ebuf))
-		return n
+		return n.cError(ebuf)
 	}
 
 	n.ospace = C.ngt_get_object_space(n.index, ebuf)
 	if n.ospace == nil {
-		n.errs = append(n.errs, newGoError(ebuf))
-		return n
+		return n.cError(ebuf)
 	}
-
-	return n
+	return nil
 }
 
 // StrictSearch is C type stricted search function
 func StrictSearch(vec []float64, size int, epsilon, radius float32) ([]StrictSearchResult, error) {
-	return ngt.StrictSearch(vec, size, epsilon, radius)
+	return Get().StrictSearch(vec, size, epsilon, radius)
 }
 
 // StrictSearch is C type stricted search function
 func (n *NGT) StrictSearch(vec []float64, size int, epsilon, radius float32) ([]StrictSearchResult, error) {
+	return n.StrictSearchContext(context.Background(), vec, size, epsilon, radius)
+}
+
+// StrictSearchContext is StrictSearch traced as a child of the span in ctx
+func StrictSearchContext(ctx context.Context, vec []float64, size int, epsilon, radius float32) ([]StrictSearchResult, error) {
+	return Get().StrictSearchContext(ctx, vec, size, epsilon, radius)
+}
+
+// StrictSearchContext is StrictSearch traced as a child of the span in ctx
+func (n *NGT) StrictSearchContext(ctx context.Context, vec []float64, size int, epsilon, radius float32) ([]StrictSearchResult, error) {
+	start := time.Now()
+	result, err := n.strictSearch(ctx, vec, size, epsilon, radius)
+	elapsed := time.Since(start)
+	n.observe(OpSearch, start, err)
+	if err == nil && n.metrics != nil {
+		n.metrics.ObserveResultSize(len(result))
+	}
+	n.logSearch(elapsed, size, epsilon, len(result))
+	return result, err
+}
+
+func (n *NGT) strictSearch(ctx context.Context, vec []float64, size int, epsilon, radius float32) ([]StrictSearchResult, error) {
+	if len(vec) < n.prop.Dimension {
+		return nil, fmt.Errorf("%w: %d, wanted: %d", ErrDimension, len(vec), n.prop.Dimension)
+	}
 	ebuf := C.ngt_create_error_object()
 	defer C.ngt_destroy_error_object(ebuf)
 
 	results := C.ngt_create_empty_results(ebuf)
 	defer C.ngt_destroy_results(results)
 	if results == nil {
-		return nil, newGoError(ebuf)
+		return nil, n.cError(ebuf)
 	}
 
-	n.mu.RLock()
-	ret := C.ngt_search_index(n.index, (*C.double)(&vec[0]), C.int32_t(n.prop.Dimension), C.size_t(size), C.float(epsilon), C.float(radius), results, ebuf)
-	n.mu.RUnlock()
-	if ret == ErrorCode {
-		return nil, newGoError(ebuf)
+	// read-only index is never modified, searches need no lock
+	if !n.readOnly {
+		n.mu.RLock()
+		defer n.mu.RUnlock()
+	}
+	span := n.startSpan(ctx, SpanSearch, Attribute{"ngt.k", size}, Attribute{"ngt.epsilon", float64(epsilon)})
+	if C.ngt_search_index(n.index, (*C.double)(&vec[0]), C.int32_t(n.prop.Dimension), C.size_t(size), C.float(epsilon), C.float(radius), results, ebuf) == ErrorCode {
+		err := n.cError(ebuf)
+		endSpan(span, err)
+		return nil, err
 	}
 	rsize := int(C.ngt_get_size(results, ebuf))
 	if rsize == -1 {
-		return nil, newGoError(ebuf)
+		err := n.cError(ebuf)
+		endSpan(span, err)
+		return nil, err
 	}
+	endSpan(span, nil, Attribute{"ngt.results", rsize})
 	result := make([]StrictSearchResult, rsize)
 	for i := 0; i < rsize; i++ {
 		d := C.ngt_get_result(results, C.uint32_t(i), ebuf)
@@ -479,18 +680,52 @@ func (n *NGT) StrictSearch(vec []float64, size int, epsilon, radius float32) ([]
 			result[i] = StrictSearchResult{uint32(d.id), float32(d.distance), nil}
 		}
 	}
+	if n.staged != nil {
+		result = append(result, n.staged.search(n.prop.DistanceType, n.stagedQuery(vec), size, radius)...)
+		sortResults(result)
+		if len(result) > size {
+			result = result[:size]
+		}
+	}
 
 	return result, nil
 }
 
 // Search returns search result as []SearchResult
 func Search(vec []float64, size int, epsilon float64) ([]SearchResult, error) {
-	return ngt.Search(vec, size, epsilon)
+	return Get().Search(vec, size, epsilon)
 }
 
 // Search returns search result as []SearchResult
 func (n *NGT) Search(vec []float64, size int, epsilon float64) ([]SearchResult, error) {
-	res, err := n.StrictSearch(vec, size, float32(epsilon), -1.0)
+	return n.SearchContext(context.Background(), vec, size, epsilon)
+}
+
+// SearchContext is Search traced as a child of the span in ctx
+func SearchContext(ctx context.Context, vec []float64, size int, epsilon float64) ([]SearchResult, error) {
+	return Get().SearchContext(ctx, vec, size, epsilon)
+}
+
+// SearchContext is Search traced as a child of the span in ctx
+func (n *NGT) SearchContext(ctx context.Context, vec []float64, size int, epsilon float64) ([]SearchResult, error) {
+	var key string
+	var gen uint64
+	cache := n.cache.Load()
+	if cache != nil {
+		var result []SearchResult
+		var ok bool
+		start := time.Now()
+		key = cache.key(vec, size, epsilon)
+		if result, gen, ok = cache.get(key); ok {
+			n.observe(OpSearch, start, nil)
+			if n.metrics != nil {
+				n.metrics.ObserveResultSize(len(result))
+			}
+			return result, nil
+		}
+	}
+
+	res, err := n.StrictSearchContext(ctx, vec, size, float32(epsilon), -1.0)
 	if err != nil {
 		return nil, err
 	}
@@ -502,35 +737,70 @@ func (n *NGT) Search(vec []float64, size int, epsilon float64) ([]SearchResult,
 			idx++
 		}
 	}
+	if cache != nil {
+		cache.put(key, result[:idx], gen)
+	}
 	return result[:idx], nil
 }
 
 // StrictInsert is C type stricted insert function
 func StrictInsert(vec []float64) (uint, error) {
-	return ngt.StrictInsert(vec)
+	return Get().StrictInsert(vec)
 }
 
 // StrictInsert is C type stricted insert function
 func (n *NGT) StrictInsert(vec []float64) (uint, error) {
+	start := time.Now()
+	n.mu.Lock()
+	id, err := n.insert(vec)
+	if err == nil && n.wal != nil {
+		err = n.wal.append(walRecord{op: walInsert, id: id, vec: vec})
+	}
+	n.mu.Unlock()
+	n.observe(OpInsert, start, err)
+	if err != nil {
+		n.errs = append(n.errs, err)
+		return id, err
+	}
+
+	return id, nil
+}
+
+// insert calls ngt_insert_index, caller must hold write lock.
+func (n *NGT) insert(vec []float64) (uint, error) {
+	if n.readOnly {
+		return 0, ErrReadOnly
+	}
 	ebuf := C.ngt_create_error_object()
 	defer C.ngt_destroy_error_object(ebuf)
 
-	n.mu.Lock()
+	span := n.startSpan(context.Background(), SpanInsert)
 	id := C.ngt_insert_index(n.index, (*C.double)(&vec[0]), C.uint32_t(n.prop.Dimension), ebuf)
-	n.mu.Unlock()
 	if id == 0 {
-		err := newGoError(ebuf)
-		n.errs = append(n.errs, err)
+		err := n.cError(ebuf)
+		endSpan(span, err)
 		return 0, err
 	}
-
+	endSpan(span, nil)
+	if uint(id) >= n.repoSize {
+		n.repoSize = uint(id) + 1
+	}
+	n.invalidateCache()
+	n.addObjects(1)
+	if n.staged != nil {
+		obj, err := n.getObject(uint(id))
+		if err != nil {
+			return uint(id), err
+		}
+		n.staged.add(uint(id), obj)
+	}
 	return uint(id), nil
 }
 
 // Insert returns NGT object id.
 // This only stores not indexing, must execute CreateIndex and SaveIndex.
 func Insert(vec []float64) (int, error) {
-	return ngt.Insert(vec)
+	return Get().Insert(vec)
 }
 
 // Insert returns NGT object id.
@@ -543,7 +813,7 @@ func (n *NGT) Insert(vec []float64) (int, error) {
 // InsertCommit returns NGT object id.
 // This stores and indexes at the same time.
 func InsertCommit(vec []float64, poolSize int) (int, error) {
-	return ngt.InsertCommit(vec, poolSize)
+	return Get().InsertCommit(vec, poolSize)
 }
 
 // InsertCommit returns NGT object id.
@@ -570,7 +840,7 @@ func (n *NGT) InsertCommit(vec []float64, poolSize int) (int, error) {
 // BulkInsert returns NGT object ids.
 // This only stores not indexing, you must call CreateIndex and SaveIndex.
 func BulkInsert(vecs [][]float64) ([]int, []error) {
-	return ngt.BulkInsert(vecs)
+	return Get().BulkInsert(vecs)
 }
 
 // BulkInsert returns NGT object ids.
@@ -596,7 +866,7 @@ func (n *NGT) BulkInsert(vecs [][]float64) ([]int, []error) {
 // BulkInsertCommit returns NGT object ids.
 // This stores and indexes at the same time.
 func BulkInsertCommit(vecs [][]float64, poolSize int) ([]int, []error) {
-	return ngt.BulkInsertCommit(vecs, poolSize)
+	return Get().BulkInsertCommit(vecs, poolSize)
 }
 
 // BulkInsertCommit returns NGT object ids.
@@ -631,7 +901,7 @@ func (n *NGT) BulkInsertCommit(vecs [][]float64, poolSize int) ([]int, []error)
 
 // CreateAndSaveIndex call  CreateIndex and SaveIndex in a row.
 func CreateAndSaveIndex(poolSize int) error {
-	return ngt.CreateAndSaveIndex(poolSize)
+	return Get().CreateAndSaveIndex(poolSize)
 }
 
 // CreateAndSaveIndex call  CreateIndex and SaveIndex in a row.
@@ -645,42 +915,76 @@ func (n *NGT) CreateAndSaveIndex(poolSize int) error {
 
 // CreateIndex creates NGT index.
 func CreateIndex(poolSize int) error {
-	return ngt.CreateIndex(poolSize)
+	return Get().CreateIndex(poolSize)
 }
 
 // CreateIndex creates NGT index.
 func (n *NGT) CreateIndex(poolSize int) error {
-	ebuf := C.ngt_create_error_object()
-	defer C.ngt_destroy_error_object(ebuf)
-
+	start := time.Now()
 	n.mu.Lock()
-	ret := C.ngt_create_index(n.index, C.uint32_t(poolSize), ebuf)
+	err := n.createIndex(poolSize)
+	if err == nil && n.wal != nil {
+		err = n.wal.append(walRecord{op: walCreateIndex, id: uint(poolSize)})
+	}
 	n.mu.Unlock()
-	if ret == ErrorCode {
-		err := newGoError(ebuf)
+	n.observe(OpCreateIndex, start, err)
+	if err != nil {
 		n.errs = append(n.errs, err)
 		return err
 	}
+	n.log(slog.LevelInfo, "created index", "pool_size", poolSize, "duration", time.Since(start))
+
+	return nil
+}
+
+// createIndex calls ngt_create_index, caller must hold write lock.
+func (n *NGT) createIndex(poolSize int) error {
+	if n.readOnly {
+		return ErrReadOnly
+	}
+	ebuf := C.ngt_create_error_object()
+	defer C.ngt_destroy_error_object(ebuf)
 
+	span := n.startSpan(context.Background(), SpanCreateIndex, Attribute{"ngt.pool_size", poolSize})
+	if C.ngt_create_index(n.index, C.uint32_t(poolSize), ebuf) == ErrorCode {
+		err := n.cError(ebuf)
+		endSpan(span, err)
+		return err
+	}
+	endSpan(span, nil)
+	n.invalidateCache()
+	if n.staged != nil {
+		// objects removed while staged are in the graph now
+		for id := range n.staged.reset() {
+			if err := n.removeNode(id); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
 // SaveIndex stores NGT index to storage.
 func SaveIndex() error {
-	return ngt.SaveIndex()
+	return Get().SaveIndex()
 }
 
 // SaveIndex stores NGT index to storage.
 func (n *NGT) SaveIndex() error {
-	ebuf := C.ngt_create_error_object()
-	defer C.ngt_destroy_error_object(ebuf)
+	if err := n.flushStaging(); err != nil {
+		return err
+	}
 
+	n.smu.Lock()
+	defer n.smu.Unlock()
+
+	start := time.Now()
 	n.mu.RLock()
-	ret := C.ngt_save_index(n.index, C.CString(n.prop.IndexPath), ebuf)
+	err := n.saveIndex()
 	n.mu.RUnlock()
+	n.observe(OpSaveIndex, start, err)
 
-	if ret == ErrorCode {
-		err := newGoError(ebuf)
+	if err != nil {
 		n.errs = append(n.errs, err)
 		return err
 	}
@@ -690,19 +994,20 @@ func (n *NGT) SaveIndex() error {
 
 // StrictRemove is C type stricted remove function
 func StrictRemove(id uint) error {
-	return ngt.StrictRemove(id)
+	return Get().StrictRemove(id)
 }
 
 // StrictRemove is C type stricted remove function
 func (n *NGT) StrictRemove(id uint) error {
-	ebuf := C.ngt_create_error_object()
-	defer C.ngt_destroy_error_object(ebuf)
-
+	start := time.Now()
 	n.mu.Lock()
-	ret := C.ngt_remove_index(n.index, C.ObjectID(id), ebuf)
+	err := n.remove(id)
+	if err == nil && n.wal != nil {
+		err = n.wal.append(walRecord{op: walRemove, id: id})
+	}
 	n.mu.Unlock()
-	if ret == ErrorCode {
-		err := newGoError(ebuf)
+	n.observe(OpRemove, start, err)
+	if err != nil {
 		n.errs = append(n.errs, err)
 		return err
 	}
@@ -710,9 +1015,43 @@ func (n *NGT) StrictRemove(id uint) error {
 	return nil
 }
 
+// remove calls ngt_remove_index, caller must hold write lock.
+func (n *NGT) remove(id uint) error {
+	if n.readOnly {
+		return ErrReadOnly
+	}
+	n.invalidateCache()
+	if n.staged != nil {
+		if n.staged.isRemoved(id) {
+			return fmt.Errorf("%w: %d", ErrObjectID, id)
+		}
+		if n.staged.remove(id) {
+			// not in the graph yet, removed by the next createIndex
+			n.addObjects(-1)
+			return nil
+		}
+	}
+	if err := n.removeNode(id); err != nil {
+		return err
+	}
+	n.addObjects(-1)
+	return nil
+}
+
+// removeNode calls ngt_remove_index, caller must hold write lock.
+func (n *NGT) removeNode(id uint) error {
+	ebuf := C.ngt_create_error_object()
+	defer C.ngt_destroy_error_object(ebuf)
+
+	if C.ngt_remove_index(n.index, C.ObjectID(id), ebuf) == ErrorCode {
+		return n.cError(ebuf)
+	}
+	return nil
+}
+
 // Remove removes from NGT index.
 func Remove(id int) error {
-	return ngt.Remove(id)
+	return Get().Remove(id)
 }
 
 // Remove removes from NGT index.
@@ -722,53 +1061,72 @@ func (n *NGT) Remove(id int) error {
 
 // GetStrictVector is C type stricted GetVector function.
 func GetStrictVector(id uint) ([]float32, error) {
-	return ngt.GetStrictVector(id)
+	return Get().GetStrictVector(id)
 }
 
 // GetStrictVector is C type stricted GetVector function.
 func (n *NGT) GetStrictVector(id uint) ([]float32, error) {
+	n.mu.RLock()
+	ret, err := n.getObject(id)
+	n.mu.RUnlock()
+	if err != nil {
+		n.errs = append(n.errs, err)
+		return nil, err
+	}
+	return ret, nil
+}
+
+// getObject copies object from NGT object space, caller must hold read lock.
+func (n *NGT) getObject(id uint) ([]float32, error) {
+	if n.staged != nil && n.staged.isRemoved(id) {
+		return nil, fmt.Errorf("%w: %d", ErrObjectID, id)
+	}
 	ebuf := C.ngt_create_error_object()
 	defer C.ngt_destroy_error_object(ebuf)
 
 	ret := make([]float32, n.prop.Dimension)
 	switch n.prop.ObjectType {
 	case Float:
-		n.mu.RLock()
 		results := C.ngt_get_object_as_float(n.ospace, C.ObjectID(id), ebuf)
-		n.mu.RUnlock()
 		if results == nil {
-			err := newGoError(ebuf)
-			n.errs = append(n.errs, err)
-			return nil, err
+			return nil, newGoError(ebuf)
 		}
 		slice := (*[1 << 30]C.float)(unsafe.Pointer(results))[:n.prop.Dimension:n.prop.Dimension]
 		for i := 0; i < n.prop.Dimension; i++ {
 			ret[i] = float32(slice[i])
 		}
 	case Uint8:
-		n.mu.RLock()
 		results := C.ngt_get_object_as_integer(n.ospace, C.ObjectID(id), ebuf)
-		n.mu.RUnlock()
 		if results == nil {
-			err := newGoError(ebuf)
-			n.errs = append(n.errs, err)
-			return nil, err
+			return nil, newGoError(ebuf)
 		}
 		slice := (*[1 << 30]C.uchar)(unsafe.Pointer(results))[:n.prop.Dimension:n.prop.Dimension]
 		for i := 0; i < n.prop.Dimension; i++ {
 			ret[i] = float32(slice[i])
 		}
 	default:
-		err := errors.New("Unsupported ObjectType")
-		n.errs = append(n.errs, err)
-		return nil, err
+		return nil, errors.New("Unsupported ObjectType")
 	}
 	return ret, nil
 }
 
+// rangeObjects calls fn for every live object until fn returns false.
+// Removed slots are skipped, caller must hold read lock.
+func (n *NGT) rangeObjects(fn func(id uint, vec []float32) bool) {
+	for id := uint(1); id < n.repoSize; id++ {
+		vec, err := n.getObject(id)
+		if err != nil {
+			continue
+		}
+		if !fn(id, vec) {
+			return
+		}
+	}
+}
+
 // GetVector returns vector stored in NGT index.
 func GetVector(id int) ([]float64, error) {
-	return ngt.GetVector(id)
+	return Get().GetVector(id)
 }
 
 // GetVector returns vector stored in NGT index.
@@ -785,24 +1143,78 @@ func (n *NGT) GetVector(id int) ([]float64, error) {
 	return ret, nil
 }
 
+// Len returns the number of live objects in NGT index.
+func Len() int {
+	return Get().Len()
+}
+
+// Len returns the number of live objects in NGT index.
+func (n *NGT) Len() int {
+	size := 0
+	n.Range(func(int, []float32) bool {
+		size++
+		return true
+	})
+	return size
+}
+
+// IDs returns ids of live objects in ascending order.
+func IDs() []int {
+	return Get().IDs()
+}
+
+// IDs returns ids of live objects in ascending order.
+func (n *NGT) IDs() []int {
+	ids := make([]int, 0)
+	n.Range(func(id int, _ []float32) bool {
+		ids = append(ids, id)
+		return true
+	})
+	return ids
+}
+
+// Range calls fn for every live object in ascending id order until fn returns false.
+// Removed objects are skipped. fn must not modify NGT index.
+func Range(fn func(id int, vec []float32) bool) {
+	Get().Range(fn)
+}
+
+// Range calls fn for every live object in ascending id order until fn returns false.
+// Removed objects are skipped. fn must not modify NGT index.
+func (n *NGT) Range(fn func(id int, vec []float32) bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	n.rangeObjects(func(id uint, vec []float32) bool {
+		return fn(int(id), vec)
+	})
+}
+
 // Close NGT index.
 func Close() {
-	if ngt != nil {
-		ngt.Close()
+	if n, ok := DefaultRegistry.Lookup(DefaultName); ok {
+		n.Close()
 	}
 }
 
 // Close NGT index.
 func (n *NGT) Close() {
+	n.closeStaging()
+	n.invalidateCache()
 	if n.index != nil {
 		C.ngt_close_index(n.index)
 		n.index = nil
 	}
+	if n.wal != nil {
+		if err := n.wal.close(); err != nil {
+			n.errs = append(n.errs, err)
+		}
+		n.wal = nil
+	}
 }
 
 // GetErrors returns errors
 func GetErrors() []error {
-	return ngt.errs
+	return Get().GetErrors()
 }
 
 // GetErrors returns errors
@@ -14,6 +14,8 @@
 // limitations under the License.
 //
 
+//go:build cgo && !purego
+
 package gongt_test
 
 import (
@@ -22,7 +24,7 @@ import (
 	"testing"
 
 	"github.com/yahoojapan/gongt"
-	"gonum.org/v1/hdf5"
+	"github.com/yahoojapan/gongt/dataset"
 )
 
 type data struct {
@@ -31,14 +33,14 @@ type data struct {
 }
 
 var (
-	fashionmnist = data{"Fashion-MNIST", "assets/bench/fashion-mnist-784-euclidean.hdf5"}
-	glove25      = data{"GloVe-25", "assets/bench/glove-25-angular.hdf5"}
-	glove50      = data{"GloVe-50", "assets/bench/glove-50-angular.hdf5"}
-	glove100     = data{"GloVe-100", "assets/bench/glove-100-angular.hdf5"}
-	glove200     = data{"GloVe-200", "assets/bench/glove-200-angular.hdf5"}
-	mnist        = data{"MNIST", "assets/bench/mnist-784-euclidean.hdf5"}
-	nytimes      = data{"NYTimes", "assets/bench/nytimes-256-angular.hdf5"}
-	sift         = data{"SIFT", "assets/bench/sift-128-euclidean.hdf5"}
+	fashionmnist = data{"Fashion-MNIST", "assets/bench/fashion-mnist-784-euclidean.gds"}
+	glove25      = data{"GloVe-25", "assets/bench/glove-25-angular.gds"}
+	glove50      = data{"GloVe-50", "assets/bench/glove-50-angular.gds"}
+	glove100     = data{"GloVe-100", "assets/bench/glove-100-angular.gds"}
+	glove200     = data{"GloVe-200", "assets/bench/glove-200-angular.gds"}
+	mnist        = data{"MNIST", "assets/bench/mnist-784-euclidean.gds"}
+	nytimes      = data{"NYTimes", "assets/bench/nytimes-256-angular.gds"}
+	sift         = data{"SIFT", "assets/bench/sift-128-euclidean.gds"}
 )
 
 func BenchmarkFashionMNIST(b *testing.B) {
@@ -79,42 +81,16 @@ func benchmarkAll(b *testing.B, d data) {
 }
 
 func load(path, name string) ([][]float64, error) {
-	f, err := hdf5.OpenFile(path, hdf5.F_ACC_RDONLY)
+	f, err := dataset.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
-	dset, err := f.OpenDataset(name)
-	if err != nil {
-		return nil, err
-	}
-	defer dset.Close()
-	space := dset.Space()
-	defer space.Close()
-	dims, _, err := space.SimpleExtentDims()
-	if err != nil {
-		return nil, err
-	}
-	v := make([]float32, space.SimpleExtentNPoints())
-	if err := dset.Read(&v); err != nil {
-		return nil, err
-	}
-
-	row := int(dims[0])
-	col := int(dims[1])
-
-	vec := make([][]float64, row)
-	for i := 0; i < row; i++ {
-		vec[i] = make([]float64, col)
-		for j := 0; j < col; j++ {
-			vec[i][j] = float64(v[i*col+j])
-		}
-	}
-	return vec, nil
+	return f.Float64s(name)
 }
 
 func benchmarkInsert(b *testing.B, d data) {
-	dataset, err := load(d.path, "train")
+	vecs, err := load(d.path, dataset.Train)
 	if err != nil {
 		b.Error(err)
 	}
@@ -125,14 +101,14 @@ func benchmarkInsert(b *testing.B, d data) {
 		}
 		defer os.RemoveAll(tmpdir)
 
-		n := gongt.New(tmpdir).SetObjectType(gongt.Float).SetDimension(len(dataset[0])).Open()
+		n := gongt.New(tmpdir).SetObjectType(gongt.Float).SetDimension(len(vecs[0])).Open()
 		defer n.Close()
 
 		sb.ReportAllocs()
 		sb.ResetTimer()
 		sb.StartTimer()
 		for i := 0; i < sb.N; i++ {
-			n.Insert(dataset[i%len(dataset)])
+			n.Insert(vecs[i%len(vecs)])
 		}
 		sb.StopTimer()
 	})
@@ -144,7 +120,7 @@ func benchmarkInsert(b *testing.B, d data) {
 		}
 		defer os.RemoveAll(tmpdir)
 
-		n := gongt.New(tmpdir).SetObjectType(gongt.Float).SetDimension(len(dataset[0])).Open()
+		n := gongt.New(tmpdir).SetObjectType(gongt.Float).SetDimension(len(vecs[0])).Open()
 		defer n.Close()
 
 		sb.ReportAllocs()
@@ -153,7 +129,7 @@ func benchmarkInsert(b *testing.B, d data) {
 		sb.RunParallel(func(pb *testing.PB) {
 			i := 0
 			for pb.Next() {
-				n.Insert(dataset[i%len(dataset)])
+				n.Insert(vecs[i%len(vecs)])
 				i++
 			}
 		})
@@ -162,7 +138,7 @@ func benchmarkInsert(b *testing.B, d data) {
 }
 
 func benchmarkSearch(b *testing.B, d data) {
-	dataset, err := load(d.path, "test")
+	vecs, err := load(d.path, dataset.Test)
 	if err != nil {
 		b.Error(err)
 	}
@@ -175,7 +151,7 @@ func benchmarkSearch(b *testing.B, d data) {
 		sb.ResetTimer()
 		sb.StartTimer()
 		for i := 0; i < sb.N; i++ {
-			n.Search(dataset[i%len(dataset)], size, gongt.DefaultEpsilon)
+			n.Search(vecs[i%len(vecs)], size, gongt.DefaultEpsilon)
 		}
 		sb.StopTimer()
 	})
@@ -187,7 +163,7 @@ func benchmarkSearch(b *testing.B, d data) {
 		sb.RunParallel(func(pb *testing.PB) {
 			i := 0
 			for pb.Next() {
-				n.Search(dataset[i%len(dataset)], size, gongt.DefaultEpsilon)
+				n.Search(vecs[i%len(vecs)], size, gongt.DefaultEpsilon)
 				i++
 			}
 		})
@@ -14,6 +14,8 @@
 // limitations under the License.
 //
 
+//go:build cgo && !purego
+
 package gongt
 
 import (
@@ -398,3 +400,228 @@ func TestGetVector(t *testing.T) {
 		}
 	}
 }
+
+func TestRange(t *testing.T) {
+	vectors := [][]float32{
+		{1, 0, 0, 0, 0, 0},
+		{0, 1, 0, 0, 0, 0},
+		{0, 0, 1, 0, 0, 0},
+		{0, 0, 0, 1, 0, 0},
+		{0, 0, 0, 0, 1, 0},
+		{1, 1, 0, 0, 0, 0},
+	}
+	tests := []struct {
+		remove []int
+		want   []int
+	}{
+		{[]int{}, []int{1, 2, 3, 4, 5, 6}},
+		{[]int{2, 5}, []int{1, 3, 4, 6}},
+		{[]int{1, 6}, []int{2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		tmpdir, err := ioutil.TempDir("", "tmpdir")
+		if err != nil {
+			t.Errorf("Unexpected error: TestRange(%v)", err)
+		}
+		defer os.RemoveAll(tmpdir)
+
+		if err := exec.Command("cp", "-r", index, tmpdir).Run(); err != nil {
+			t.Errorf("Unexpected error: TestRange(%v)", err)
+		}
+
+		ngt := New(path.Join(tmpdir, "index")).Open()
+		for _, id := range tt.remove {
+			if err := ngt.Remove(id); err != nil {
+				t.Errorf("Unexpected error: TestRange(%v)", err)
+			}
+		}
+		if got := ngt.IDs(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TestRange(%v): %v, wanted: %v", tt.remove, got, tt.want)
+		}
+		if got := ngt.Len(); got != len(tt.want) {
+			t.Errorf("TestRange(%v): length %v, wanted: %v", tt.remove, got, len(tt.want))
+		}
+		ngt.Range(func(id int, vec []float32) bool {
+			if want := vectors[id-1]; !reflect.DeepEqual(vec, want) {
+				t.Errorf("TestRange(%v): %v, wanted: %v", id, vec, want)
+			}
+			return true
+		})
+		ngt.Close()
+	}
+}
+
+func TestRangeStop(t *testing.T) {
+	ngt := New(index).Open()
+	defer ngt.Close()
+
+	ids := make([]int, 0)
+	ngt.Range(func(id int, _ []float32) bool {
+		ids = append(ids, id)
+		return len(ids) < 3
+	})
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("TestRangeStop: %v, wanted: %v", ids, want)
+	}
+}
+
+func TestOpenWithoutIndexPath(t *testing.T) {
+	tests := []struct {
+		ngt  *NGT
+		want []error
+	}{
+		{New(""), []error{ErrNoIndexPath}},
+		{New("").SetIndexPath(index), []error{}},
+	}
+	for i, tt := range tests {
+		tt.ngt.Open()
+		if got := tt.ngt.GetErrors(); len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+			t.Errorf("TestOpenWithoutIndexPath(%v): %v, wanted: %v", i, got, tt.want)
+		}
+		tt.ngt.Close()
+	}
+}
+
+func TestOpenInMemory(t *testing.T) {
+	tests := []struct {
+		vector []float64
+		want   int
+	}{
+		{[]float64{1, 0, 0, 0, 0, 0}, 1},
+		{[]float64{0, 1, 0, 0, 0, 0}, 2},
+		{[]float64{0, 0, 1, 0, 0, 0}, 3},
+	}
+
+	ngt := OpenInMemory(Property{Dimension: 6, ObjectType: Uint8})
+	defer ngt.Close()
+	if errs := ngt.GetErrors(); len(errs) > 0 {
+		t.Errorf("Unexpected error: TestOpenInMemory(%v)", errs)
+	}
+	if ngt.GetPath() != "" {
+		t.Errorf("TestOpenInMemory: index path %v, wanted: empty", ngt.GetPath())
+	}
+	for _, tt := range tests {
+		if _, err := ngt.Insert(tt.vector); err != nil {
+			t.Errorf("Unexpected error: TestOpenInMemory(%v)", err)
+		}
+	}
+	if err := ngt.CreateIndex(poolSize); err != nil {
+		t.Errorf("Unexpected error: TestOpenInMemory(%v)", err)
+	}
+	for _, tt := range tests {
+		result, err := ngt.Search(tt.vector, 1, DefaultEpsilon)
+		if err != nil {
+			t.Errorf("Unexpected error: TestOpenInMemory(%v)", err)
+		}
+		if len(result) == 0 || result[0].ID != tt.want {
+			t.Errorf("TestOpenInMemory(%v): %v, wanted: %v", tt.vector, result, tt.want)
+		}
+	}
+	if err := ngt.SaveIndex(); err != ErrNoIndexPath {
+		t.Errorf("TestOpenInMemory: %v, wanted: %v", err, ErrNoIndexPath)
+	}
+}
+
+func TestOpenInMemoryProperty(t *testing.T) {
+	tests := []struct {
+		prop     Property
+		distance DistanceType
+		want     float64
+		staging  bool
+	}{
+		// the zero value is L2
+		{Property{Dimension: 6}, L2, 2, false},
+		{Property{Dimension: 6, DistanceType: L1}, L1, 4, false},
+		{Property{Dimension: 6, DistanceType: L2, StagingSize: 10}, L2, 2, true},
+	}
+
+	for _, tt := range tests {
+		ngt := OpenInMemory(tt.prop)
+		if errs := ngt.GetErrors(); len(errs) > 0 {
+			t.Errorf("Unexpected error: TestOpenInMemoryProperty(%v)", errs)
+			ngt.Close()
+			continue
+		}
+		if ngt.prop.DistanceType != tt.distance || (ngt.staged != nil) != tt.staging {
+			t.Errorf("TestOpenInMemoryProperty(%+v): distance %v staging %v, wanted: %v %v", tt.prop, ngt.prop.DistanceType, ngt.staged != nil, tt.distance, tt.staging)
+		}
+		if _, err := ngt.InsertCommit([]float64{1, 1, 1, 1, 0, 0}, poolSize); err != ErrNoIndexPath {
+			t.Errorf("TestOpenInMemoryProperty: %v, wanted: %v", err, ErrNoIndexPath)
+		}
+		result, err := ngt.Search([]float64{0, 0, 0, 0, 0, 0}, 1, DefaultEpsilon)
+		if err != nil || len(result) != 1 || result[0].Distance != tt.want {
+			t.Errorf("TestOpenInMemoryProperty(%+v): %v %v, wanted distance: %v", tt.prop, result, err, tt.want)
+		}
+		ngt.Close()
+	}
+
+	ngt := OpenInMemory(Property{Dimension: 6, WriteAheadLog: true})
+	defer ngt.Close()
+	if errs := ngt.GetErrors(); len(errs) != 1 || errs[0] != ErrNoIndexPath {
+		t.Errorf("TestOpenInMemoryProperty(WriteAheadLog): %v, wanted: %v", errs, ErrNoIndexPath)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	ngt := OpenReadOnly(index)
+	defer ngt.Close()
+	if errs := ngt.GetErrors(); len(errs) > 0 {
+		t.Errorf("Unexpected error: TestOpenReadOnly(%v)", errs)
+	}
+	// the same directory can be opened many times
+	other := OpenReadOnly(index)
+	defer other.Close()
+	if errs := other.GetErrors(); len(errs) > 0 {
+		t.Errorf("Unexpected error: TestOpenReadOnly(%v)", errs)
+	}
+
+	for _, n := range []*NGT{ngt, other} {
+		result, err := n.Search([]float64{1, 0, 0, 0, 0, 0}, 1, DefaultEpsilon)
+		if err != nil {
+			t.Errorf("Unexpected error: TestOpenReadOnly(%v)", err)
+		}
+		if len(result) == 0 || result[0].ID != 1 {
+			t.Errorf("TestOpenReadOnly: %v, wanted: %v", result, 1)
+		}
+	}
+
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{"Insert", func() error {
+			_, err := ngt.Insert([]float64{1, 0, 0, 0, 0, 0})
+			return err
+		}},
+		{"Remove", func() error { return ngt.Remove(1) }},
+		{"CreateIndex", func() error { return ngt.CreateIndex(poolSize) }},
+		{"SaveIndex", func() error { return ngt.SaveIndex() }},
+		{"SaveTo", func() error { return ngt.SaveTo(index) }},
+	}
+	for _, tt := range tests {
+		if err := tt.fn(); err != ErrReadOnly {
+			t.Errorf("TestOpenReadOnly(%v): %v, wanted: %v", tt.name, err, ErrReadOnly)
+		}
+	}
+}
+
+func TestOpenReadOnlyMissing(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestOpenReadOnlyMissing(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	indexPath := path.Join(tmpdir, "index")
+	ngt := OpenReadOnly(indexPath)
+	defer ngt.Close()
+	if errs := ngt.GetErrors(); len(errs) == 0 {
+		t.Errorf("TestOpenReadOnlyMissing: missing index is opened")
+	}
+	if _, err := os.Stat(indexPath); !os.IsNotExist(err) {
+		t.Errorf("TestOpenReadOnlyMissing: index is created")
+	}
+	if _, err := ngt.Insert([]float64{1, 0, 0, 0, 0, 0}); err != ErrReadOnly {
+		t.Errorf("TestOpenReadOnlyMissing(Insert): %v, wanted: %v", err, ErrReadOnly)
+	}
+}
@@ -0,0 +1,239 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/yahoojapan/gongt/internal/ngtfile"
+)
+
+type (
+	// GraphFormat is file format written by ExportGraph
+	GraphFormat int
+
+	// graphCache keeps the parsed grp file until it is replaced by SaveIndex
+	graphCache struct {
+		modTime time.Time
+		size    int64
+		g       *ngtfile.Graph
+	}
+)
+
+const (
+	// GraphNone is unknown format
+	GraphNone GraphFormat = iota
+	// GraphTSV is edge list of source, target and distance separated by tab
+	GraphTSV
+	// GraphJSONL is JSON Lines with id and edges of each node
+	GraphJSONL
+	// GraphML is GraphML XML with distance as edge data
+	GraphML
+)
+
+var graphFormatNames = map[GraphFormat]string{
+	GraphTSV:   "tsv",
+	GraphJSONL: "jsonl",
+	GraphML:    "graphml",
+}
+
+// String returns name of format
+func (f GraphFormat) String() string {
+	if name, ok := graphFormatNames[f]; ok {
+		return name
+	}
+	return "none"
+}
+
+// ParseGraphFormat returns GraphFormat named name
+func ParseGraphFormat(name string) (GraphFormat, error) {
+	for f, n := range graphFormatNames {
+		if n == name {
+			return f, nil
+		}
+	}
+	return GraphNone, fmt.Errorf("Unknown graph format %q", name)
+}
+
+// Neighbors returns edges of node id in default NGT index.
+func Neighbors(id int) ([]SearchResult, error) {
+	return Get().Neighbors(id)
+}
+
+// Neighbors returns edges of node id sorted as stored in the graph.
+// The graph is read from IndexPath and reflects the last SaveIndex.
+func (n *NGT) Neighbors(id int) ([]SearchResult, error) {
+	g, err := n.savedGraph()
+	if err != nil {
+		n.errs = append(n.errs, err)
+		return nil, err
+	}
+	if id <= 0 || id >= len(g.Nodes) || g.Nodes[id] == nil {
+		err = fmt.Errorf("node %d not found", id)
+		n.errs = append(n.errs, err)
+		return nil, err
+	}
+
+	edges := g.Nodes[id].Edges
+	ret := make([]SearchResult, len(edges))
+	for i, e := range edges {
+		ret[i] = SearchResult{
+			ID:       int(e.ID),
+			Distance: float64(e.Distance),
+		}
+	}
+	return ret, nil
+}
+
+// ExportGraph writes the graph of default NGT index to w.
+func ExportGraph(w io.Writer, format GraphFormat) error {
+	return Get().ExportGraph(w, format)
+}
+
+// ExportGraph writes the graph of NGT index to w.
+// The graph is read from IndexPath and reflects the last SaveIndex.
+func (n *NGT) ExportGraph(w io.Writer, format GraphFormat) error {
+	g, err := n.savedGraph()
+	if err == nil {
+		err = writeGraph(w, g, format)
+	}
+	if err != nil {
+		n.errs = append(n.errs, err)
+		return err
+	}
+	return nil
+}
+
+// savedGraph returns the parsed grp file in IndexPath.
+func (n *NGT) savedGraph() (*ngtfile.Graph, error) {
+	if n.prop.IndexPath == "" {
+		return nil, ErrNoIndexPath
+	}
+	n.smu.Lock()
+	defer n.smu.Unlock()
+
+	path := filepath.Join(n.prop.IndexPath, ngtfile.GraphFile)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if c := n.gcache; c != nil && c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+		return c.g, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	g, err := ngtfile.ReadGraph(f)
+	if err != nil {
+		return nil, err
+	}
+	n.gcache = &graphCache{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		g:       g,
+	}
+	return g, nil
+}
+
+func writeGraph(w io.Writer, g *ngtfile.Graph, format GraphFormat) error {
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, 0, 64)
+	appendDistance := func(buf []byte, d float32) []byte {
+		return strconv.AppendFloat(buf, float64(d), 'g', -1, 32)
+	}
+
+	switch format {
+	case GraphTSV:
+		for id, node := range g.Nodes {
+			if node == nil {
+				continue
+			}
+			for _, e := range node.Edges {
+				buf = strconv.AppendInt(buf[:0], int64(id), 10)
+				buf = append(buf, '\t')
+				buf = strconv.AppendUint(buf, uint64(e.ID), 10)
+				buf = append(buf, '\t')
+				buf = appendDistance(buf, e.Distance)
+				buf = append(buf, '\n')
+				if _, err := bw.Write(buf); err != nil {
+					return err
+				}
+			}
+		}
+	case GraphJSONL:
+		for id, node := range g.Nodes {
+			if node == nil {
+				continue
+			}
+			buf = append(buf[:0], `{"id":`...)
+			buf = strconv.AppendInt(buf, int64(id), 10)
+			buf = append(buf, `,"edges":[`...)
+			for i, e := range node.Edges {
+				if i > 0 {
+					buf = append(buf, ',')
+				}
+				buf = append(buf, `{"id":`...)
+				buf = strconv.AppendUint(buf, uint64(e.ID), 10)
+				buf = append(buf, `,"distance":`...)
+				buf = appendDistance(buf, e.Distance)
+				buf = append(buf, '}')
+			}
+			buf = append(buf, "]}\n"...)
+			if _, err := bw.Write(buf); err != nil {
+				return err
+			}
+		}
+	case GraphML:
+		bw.WriteString(xmlHeader)
+		bw.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+		bw.WriteString(`<key id="distance" for="edge" attr.name="distance" attr.type="float"/>` + "\n")
+		bw.WriteString(`<graph id="ngt" edgedefault="directed">` + "\n")
+		for id, node := range g.Nodes {
+			if node != nil {
+				fmt.Fprintf(bw, "<node id=\"n%d\"/>\n", id)
+			}
+		}
+		for id, node := range g.Nodes {
+			if node == nil {
+				continue
+			}
+			for _, e := range node.Edges {
+				buf = appendDistance(buf[:0], e.Distance)
+				if _, err := fmt.Fprintf(bw, "<edge source=\"n%d\" target=\"n%d\"><data key=\"distance\">%s</data></edge>\n", id, e.ID, buf); err != nil {
+					return err
+				}
+			}
+		}
+		bw.WriteString("</graph>\n</graphml>\n")
+	default:
+		return fmt.Errorf("Unknown graph format %v", format)
+	}
+	return bw.Flush()
+}
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
@@ -0,0 +1,108 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNeighbors(t *testing.T) {
+	sqrt2 := float64(float32(1.4142135))
+	tests := []struct {
+		id   int
+		want []SearchResult
+	}{
+		{1, []SearchResult{{6, 1}, {2, sqrt2}, {3, sqrt2}, {4, sqrt2}, {5, sqrt2}}},
+		{2, []SearchResult{{6, 1}, {1, sqrt2}, {3, sqrt2}, {4, sqrt2}, {5, sqrt2}}},
+		{0, nil},
+		{7, nil},
+	}
+
+	ngt := New(index).Open()
+	defer ngt.Close()
+	for _, tt := range tests {
+		got, err := ngt.Neighbors(tt.id)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("TestNeighbors(%v): missing node is found", tt.id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error: TestNeighbors(%v)", err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TestNeighbors(%v): %v, wanted: %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestExportGraph(t *testing.T) {
+	tests := []struct {
+		format GraphFormat
+		first  string
+		lines  int
+	}{
+		{GraphTSV, "1\t6\t1", 30},
+		{GraphJSONL, `{"id":1,"edges":[{"id":6,"distance":1},{"id":2,"distance":1.4142135},{"id":3,"distance":1.4142135},{"id":4,"distance":1.4142135},{"id":5,"distance":1.4142135}]}`, 6},
+		{GraphML, `<?xml version="1.0" encoding="UTF-8"?>`, 42},
+	}
+
+	ngt := New(index).Open()
+	defer ngt.Close()
+	for _, tt := range tests {
+		format, err := ParseGraphFormat(tt.format.String())
+		if err != nil || format != tt.format {
+			t.Errorf("TestExportGraph(%v): parsed %v, %v", tt.format, format, err)
+		}
+
+		buf := new(bytes.Buffer)
+		if err := ngt.ExportGraph(buf, tt.format); err != nil {
+			t.Errorf("Unexpected error: TestExportGraph(%v)", err)
+		}
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		if lines[0] != tt.first {
+			t.Errorf("TestExportGraph(%v): %v, wanted: %v", tt.format, lines[0], tt.first)
+		}
+		if len(lines) != tt.lines {
+			t.Errorf("TestExportGraph(%v): %v lines, wanted: %v", tt.format, len(lines), tt.lines)
+		}
+		if tt.format == GraphML {
+			var v struct{}
+			if err := xml.Unmarshal(buf.Bytes(), &v); err != nil {
+				t.Errorf("Unexpected error: TestExportGraph(%v)", err)
+			}
+		}
+	}
+}
+
+func TestGraphInMemory(t *testing.T) {
+	ngt := OpenInMemory(Property{Dimension: 6})
+	defer ngt.Close()
+	if _, err := ngt.Neighbors(1); err != ErrNoIndexPath {
+		t.Errorf("TestGraphInMemory(Neighbors): %v, wanted: %v", err, ErrNoIndexPath)
+	}
+	if err := ngt.ExportGraph(new(bytes.Buffer), GraphTSV); err != ErrNoIndexPath {
+		t.Errorf("TestGraphInMemory(ExportGraph): %v, wanted: %v", err, ErrNoIndexPath)
+	}
+}
@@ -0,0 +1,31 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package gongt
+
+// Index is the part of NGT index used by most applications.
+// It is implemented by *NGT and by MemoryIndex, which needs no cgo,
+// so code taking Index can be tested with the purego build tag.
+//	var index gongt.Index = gongt.New("index Path").Open()
+type Index interface {
+	Insert(vec []float64) (int, error)
+	Search(vec []float64, size int, epsilon float64) ([]SearchResult, error)
+	Remove(id int) error
+	GetVector(id int) ([]float64, error)
+	CreateIndex(poolSize int) error
+	SaveIndex() error
+	Close()
+}
@@ -0,0 +1,218 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+type (
+	// Ingestor inserts vectors continuously and commits NGT index in background
+	Ingestor struct {
+		n      *NGT
+		cfg    IngestConfig
+		queue  chan ingestRequest
+		done   chan struct{}
+		mu     *sync.RWMutex
+		closed bool
+		err    error
+	}
+	// IngestConfig includes parameters for Ingestor
+	IngestConfig struct {
+		// PoolSize is passed to CreateIndex on each commit
+		PoolSize int
+		// CommitSize commits after this many inserts (defaults to BulkInsertChunkSize)
+		CommitSize int
+		// CommitInterval commits pending inserts at least this often
+		// (defaults to DefaultCommitInterval, negative disables)
+		CommitInterval time.Duration
+		// QueueSize is the number of vectors buffered before Add blocks
+		QueueSize int
+		// OnInsert is called with the result of every insert if set
+		OnInsert func(id int, err error)
+	}
+	ingestRequest struct {
+		vec   []float64
+		flush chan error
+	}
+)
+
+const (
+	// DefaultCommitInterval is 1 second
+	DefaultCommitInterval = time.Second
+	// DefaultIngestQueueSize is 1000
+	DefaultIngestQueueSize = 1000
+)
+
+var (
+	// ErrIngestorClosed raises using Ingestor after Close
+	ErrIngestorClosed = errors.New("Ingestor is closed")
+)
+
+// NewIngestor returns Ingestor for default NGT
+func NewIngestor(cfg IngestConfig) *Ingestor {
+	return Get().NewIngestor(cfg)
+}
+
+// NewIngestor returns Ingestor and starts background commit.
+// Close must be called to stop it.
+func (n *NGT) NewIngestor(cfg IngestConfig) *Ingestor {
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = DefaultPoolSize
+	}
+	if cfg.CommitSize <= 0 {
+		n.mu.RLock()
+		cfg.CommitSize = n.prop.BulkInsertChunkSize
+		n.mu.RUnlock()
+		if cfg.CommitSize <= 0 {
+			cfg.CommitSize = DefaultBulkInsertChunkSize
+		}
+	}
+	if cfg.CommitInterval == 0 {
+		cfg.CommitInterval = DefaultCommitInterval
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultIngestQueueSize
+	}
+	in := &Ingestor{
+		n:     n,
+		cfg:   cfg,
+		queue: make(chan ingestRequest, cfg.QueueSize),
+		done:  make(chan struct{}),
+		mu:    &sync.RWMutex{},
+	}
+	go in.run()
+	return in
+}
+
+// Add queues vector for insertion.
+// This blocks while the queue is full.
+func (in *Ingestor) Add(vec []float64) error {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	if in.closed {
+		return ErrIngestorClosed
+	}
+	in.queue <- ingestRequest{vec: vec}
+	return nil
+}
+
+// Consume queues every vector received from ch until ch is closed.
+func (in *Ingestor) Consume(ch <-chan []float64) error {
+	for vec := range ch {
+		if err := in.Add(vec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConsumeFunc queues vectors returned by next until it returns false.
+func (in *Ingestor) ConsumeFunc(next func() ([]float64, bool)) error {
+	for {
+		vec, ok := next()
+		if !ok {
+			return nil
+		}
+		if err := in.Add(vec); err != nil {
+			return err
+		}
+	}
+}
+
+// Flush waits until every vector queued before the call is inserted, indexed and saved.
+func (in *Ingestor) Flush() error {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	if in.closed {
+		return ErrIngestorClosed
+	}
+	ch := make(chan error, 1)
+	in.queue <- ingestRequest{flush: ch}
+	return <-ch
+}
+
+// Close stops accepting vectors, commits the remaining ones and returns the first commit error.
+func (in *Ingestor) Close() error {
+	in.mu.Lock()
+	if !in.closed {
+		in.closed = true
+		close(in.queue)
+	}
+	in.mu.Unlock()
+	<-in.done
+	return in.err
+}
+
+func (in *Ingestor) run() {
+	defer close(in.done)
+
+	var tick <-chan time.Time
+	if in.cfg.CommitInterval > 0 {
+		ticker := time.NewTicker(in.cfg.CommitInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	pending := 0
+	for {
+		select {
+		case req, ok := <-in.queue:
+			if !ok {
+				in.commit(&pending, true)
+				return
+			}
+			if req.flush != nil {
+				req.flush <- in.commit(&pending, true)
+				continue
+			}
+			id, err := in.n.Insert(req.vec)
+			if err == nil {
+				pending++
+			}
+			if in.cfg.OnInsert != nil {
+				in.cfg.OnInsert(id, err)
+			}
+			if pending >= in.cfg.CommitSize {
+				in.commit(&pending, false)
+			}
+		case <-tick:
+			if pending > 0 {
+				in.commit(&pending, false)
+			}
+		}
+	}
+}
+
+func (in *Ingestor) commit(pending *int, force bool) error {
+	if *pending == 0 && !force {
+		return nil
+	}
+	err := in.n.CreateAndSaveIndex(in.cfg.PoolSize)
+	if err != nil {
+		if in.err == nil {
+			in.err = err
+		}
+		return err
+	}
+	*pending = 0
+	return nil
+}
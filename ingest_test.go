@@ -0,0 +1,173 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIngestor(t *testing.T) {
+	tests := []struct {
+		vector []float64
+		want   int
+	}{
+		{[]float64{1, 0, 0, 0, 0, 0}, 1},
+		{[]float64{0, 1, 0, 0, 0, 0}, 2},
+		{[]float64{0, 0, 1, 0, 0, 0}, 3},
+		{[]float64{0, 0, 0, 1, 0, 0}, 4},
+		{[]float64{0, 0, 0, 0, 1, 0}, 5},
+		{[]float64{0, 0, 0, 0, 0, 1}, 6},
+		{[]float64{1, 1, 0, 0, 0, 0}, 7},
+	}
+
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestIngestor(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	ngt := New(tmpdir).SetObjectType(Uint8).SetDimension(6).Open()
+	if errs := ngt.GetErrors(); len(errs) > 0 {
+		t.Errorf("Unexpected error: TestIngestor(%v)", errs)
+	}
+
+	ids := make([]int, 0, len(tests))
+	in := ngt.NewIngestor(IngestConfig{
+		PoolSize:       poolSize,
+		CommitSize:     3,
+		CommitInterval: time.Millisecond,
+		QueueSize:      2,
+		OnInsert: func(id int, err error) {
+			if err != nil {
+				t.Errorf("Unexpected error: TestIngestor(%v)", err)
+			}
+			ids = append(ids, id)
+		},
+	})
+
+	ch := make(chan []float64)
+	go func() {
+		for _, tt := range tests {
+			ch <- tt.vector
+		}
+		close(ch)
+	}()
+	if err := in.Consume(ch); err != nil {
+		t.Errorf("Unexpected error: TestIngestor(%v)", err)
+	}
+	if err := in.Close(); err != nil {
+		t.Errorf("Unexpected error: TestIngestor(%v)", err)
+	}
+	if err := in.Add(tests[0].vector); err != ErrIngestorClosed {
+		t.Errorf("TestIngestor: %v, wanted: %v", err, ErrIngestorClosed)
+	}
+	ngt.Close()
+
+	ngt = New(tmpdir).Open()
+	defer ngt.Close()
+	for i, tt := range tests {
+		if ids[i] != tt.want {
+			t.Errorf("TestIngestor(%v): %v, wanted: %v", tt.vector, ids[i], tt.want)
+		}
+		result, err := ngt.Search(tt.vector, 1, DefaultEpsilon)
+		if err != nil {
+			t.Errorf("Unexpected error: TestIngestor(%v)", err)
+		}
+		if len(result) == 0 || result[0].ID != tt.want {
+			t.Errorf("TestIngestor(%v): %v, wanted: %v", tt.vector, result, tt.want)
+		}
+	}
+}
+
+func TestIngestorFlush(t *testing.T) {
+	vectors := [][]float64{
+		{1, 0, 0, 0, 0, 0},
+		{0, 1, 0, 0, 0, 0},
+		{0, 0, 1, 0, 0, 0},
+	}
+
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestIngestorFlush(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	ngt := New(tmpdir).SetObjectType(Uint8).SetDimension(6).Open()
+	defer ngt.Close()
+
+	in := ngt.NewIngestor(IngestConfig{CommitSize: 100})
+	defer in.Close()
+
+	i := 0
+	if err := in.ConsumeFunc(func() ([]float64, bool) {
+		if i >= len(vectors) {
+			return nil, false
+		}
+		i++
+		return vectors[i-1], true
+	}); err != nil {
+		t.Errorf("Unexpected error: TestIngestorFlush(%v)", err)
+	}
+	if err := in.Flush(); err != nil {
+		t.Errorf("Unexpected error: TestIngestorFlush(%v)", err)
+	}
+
+	for i, vec := range vectors {
+		got, err := ngt.GetVector(i + 1)
+		if err != nil {
+			t.Errorf("Unexpected error: TestIngestorFlush(%v)", err)
+		}
+		if !reflect.DeepEqual(got, vec) {
+			t.Errorf("TestIngestorFlush(%v): %v, wanted: %v", i+1, got, vec)
+		}
+	}
+}
+
+func TestIngestorCommitInterval(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestIngestorCommitInterval(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	ngt := New(tmpdir).SetObjectType(Uint8).SetDimension(6).Open()
+	defer ngt.Close()
+
+	tests := []struct {
+		interval time.Duration
+		want     time.Duration
+	}{
+		{0, DefaultCommitInterval},
+		{time.Minute, time.Minute},
+		{-1, -1},
+	}
+	for _, tt := range tests {
+		in := ngt.NewIngestor(IngestConfig{CommitInterval: tt.interval})
+		if got := in.cfg.CommitInterval; got != tt.want {
+			t.Errorf("TestIngestorCommitInterval(%v): %v, wanted: %v", tt.interval, got, tt.want)
+		}
+		if err := in.Close(); err != nil {
+			t.Errorf("Unexpected error: TestIngestorCommitInterval(%v)", err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package ngtfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+type (
+	// Graph is the content of grp file
+	Graph struct {
+		// Nodes is indexed by object id, nil for removed slots
+		Nodes []*Node
+		// Trailer is the bytes NGT stores after the repository
+		Trailer []byte
+	}
+	// Node is outgoing edges of an object
+	Node struct {
+		Edges []Edge
+	}
+	// Edge is an outgoing edge
+	Edge struct {
+		ID       uint32
+		Distance float32
+	}
+)
+
+// ReadGraph reads grp file.
+// Counts in the file are checked against the bytes left when r is an io.Seeker,
+// such as *os.File, so a corrupt header returns ErrBrokenRepository instead of allocating.
+func ReadGraph(r io.Reader) (*Graph, error) {
+	left := remaining(r)
+	br := bufio.NewReader(r)
+	var size uint64
+	if err := binary.Read(br, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+	left -= 8
+	// every slot takes one byte at least
+	if err := checkCount("nodes", size, 1, left); err != nil {
+		return nil, err
+	}
+	g := &Graph{Nodes: make([]*Node, 0, capacity(size))}
+	buf := make([]byte, 8)
+	for id := uint64(0); id < size; id++ {
+		mark, err := br.ReadByte()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		left--
+		switch mark {
+		case removedSlot:
+			g.Nodes = append(g.Nodes, nil)
+			continue
+		case liveSlot:
+		default:
+			return nil, ErrBrokenRepository
+		}
+		if _, err = io.ReadFull(br, buf[:4]); err != nil {
+			return nil, unexpected(err)
+		}
+		left -= 4
+		count := uint64(binary.LittleEndian.Uint32(buf[:4]))
+		if err = checkCount("edges", count, 8, left); err != nil {
+			return nil, err
+		}
+		node := &Node{Edges: make([]Edge, 0, capacity(count))}
+		for i := uint64(0); i < count; i++ {
+			if _, err = io.ReadFull(br, buf); err != nil {
+				return nil, unexpected(err)
+			}
+			node.Edges = append(node.Edges, Edge{
+				ID:       binary.LittleEndian.Uint32(buf[:4]),
+				Distance: math.Float32frombits(binary.LittleEndian.Uint32(buf[4:])),
+			})
+		}
+		left -= int64(count) * 8
+		g.Nodes = append(g.Nodes, node)
+	}
+	trailer, err := ioutil.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+	g.Trailer = trailer
+	return g, nil
+}
+
+// maxPrealloc bounds slices allocated from counts which cannot be checked against the file size
+const maxPrealloc = 1 << 16
+
+// remaining returns the number of bytes left in r, or math.MaxInt64 if r cannot tell.
+func remaining(r io.Reader) int64 {
+	s, ok := r.(io.Seeker)
+	if !ok {
+		return math.MaxInt64
+	}
+	cur, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return math.MaxInt64
+	}
+	end, err := s.Seek(0, io.SeekEnd)
+	if _, serr := s.Seek(cur, io.SeekStart); err != nil || serr != nil {
+		return math.MaxInt64
+	}
+	return end - cur
+}
+
+// checkCount returns error if count entries of size bytes do not fit in left bytes.
+func checkCount(what string, count, size uint64, left int64) error {
+	if left < 0 || count > uint64(left)/size {
+		return fmt.Errorf("%w: %d %s in %d bytes", ErrBrokenRepository, count, what, left)
+	}
+	return nil
+}
+
+func capacity(count uint64) int {
+	if count > maxPrealloc {
+		return maxPrealloc
+	}
+	return int(count)
+}
+
+// WriteTo writes g in grp file format
+func (g *Graph) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, uint64(len(g.Nodes)))
+	bw.Write(buf)
+	n += 8
+	for _, node := range g.Nodes {
+		if node == nil {
+			bw.WriteByte(removedSlot)
+			n++
+			continue
+		}
+		bw.WriteByte(liveSlot)
+		binary.LittleEndian.PutUint32(buf[:4], uint32(len(node.Edges)))
+		bw.Write(buf[:4])
+		n += 5
+		for _, e := range node.Edges {
+			binary.LittleEndian.PutUint32(buf[:4], e.ID)
+			binary.LittleEndian.PutUint32(buf[4:], math.Float32bits(e.Distance))
+			bw.Write(buf)
+			n += 8
+		}
+	}
+	bw.Write(g.Trailer)
+	n += int64(len(g.Trailer))
+	return n, bw.Flush()
+}
+
+// Len returns the number of live nodes
+func (g *Graph) Len() int {
+	l := 0
+	for _, node := range g.Nodes {
+		if node != nil {
+			l++
+		}
+	}
+	return l
+}
+
+// Unreachable returns live nodes which cannot be reached following outgoing edges
+// from the first live node. NGT starts searches from nodes found in its tree,
+// so this is a heuristic for poorly connected graphs.
+func (g *Graph) Unreachable() []uint32 {
+	visited := make([]bool, len(g.Nodes))
+	queue := make([]uint32, 0, len(g.Nodes))
+	for id, node := range g.Nodes {
+		if node != nil {
+			visited[id] = true
+			queue = append(queue, uint32(id))
+			break
+		}
+	}
+	for len(queue) > 0 {
+		node := g.Nodes[queue[0]]
+		queue = queue[1:]
+		for _, e := range node.Edges {
+			if int(e.ID) < len(g.Nodes) && !visited[e.ID] && g.Nodes[e.ID] != nil {
+				visited[e.ID] = true
+				queue = append(queue, e.ID)
+			}
+		}
+	}
+
+	unreachable := make([]uint32, 0)
+	for id, node := range g.Nodes {
+		if node != nil && !visited[id] {
+			unreachable = append(unreachable, uint32(id))
+		}
+	}
+	return unreachable
+}
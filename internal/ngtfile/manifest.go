@@ -0,0 +1,165 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package ngtfile
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Manifest records versions and checksums of index files.
+	// It is stored in the same tab separated format as prf:
+	//	GongtVersion	v1.1.1
+	//	NGTVersion	1.7.3
+	//	Objects	<live objects>
+	//	File	grp	<size>	<sha256>
+	// prf of NGT has no object count, so Objects records what the saving index held.
+	Manifest struct {
+		GongtVersion string
+		NGTVersion   string
+		// Objects is -1 in manifests written without it
+		Objects int
+		Files   []FileSum
+	}
+	// FileSum is size and checksum of an index file
+	FileSum struct {
+		Name   string
+		Size   int64
+		SHA256 string
+	}
+)
+
+// ManifestFile is the name of manifest file
+const ManifestFile = "manifest"
+
+// WriteManifest computes checksums of index files in dir and writes manifest file
+// recording objects live objects, negative to omit the count.
+func WriteManifest(dir, gongtVersion, ngtVersion string, objects int) error {
+	m := &Manifest{
+		GongtVersion: gongtVersion,
+		NGTVersion:   ngtVersion,
+		Objects:      objects,
+		Files:        make([]FileSum, 0, len(Files)),
+	}
+	for _, name := range Files {
+		sum, err := sumFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		sum.Name = name
+		m.Files = append(m.Files, sum)
+	}
+
+	f, err := os.Create(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "GongtVersion\t%s\n", m.GongtVersion)
+	fmt.Fprintf(w, "NGTVersion\t%s\n", m.NGTVersion)
+	if m.Objects >= 0 {
+		fmt.Fprintf(w, "Objects\t%d\n", m.Objects)
+	}
+	for _, sum := range m.Files {
+		fmt.Fprintf(w, "File\t%s\t%d\t%s\n", sum.Name, sum.Size, sum.SHA256)
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadManifest reads manifest file in dir
+func ReadManifest(dir string) (*Manifest, error) {
+	f, err := os.Open(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := &Manifest{Objects: -1}
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		fields := strings.Split(s.Text(), "\t")
+		switch {
+		case fields[0] == "GongtVersion" && len(fields) == 2:
+			m.GongtVersion = fields[1]
+		case fields[0] == "NGTVersion" && len(fields) == 2:
+			m.NGTVersion = fields[1]
+		case fields[0] == "Objects" && len(fields) == 2:
+			objects, err := strconv.Atoi(fields[1])
+			if err != nil || objects < 0 {
+				return nil, fmt.Errorf("%s:%d: invalid Objects %q", ManifestFile, line, fields[1])
+			}
+			m.Objects = objects
+		case fields[0] == "File" && len(fields) == 4:
+			size, err := strconv.ParseInt(fields[2], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", ManifestFile, line, err)
+			}
+			m.Files = append(m.Files, FileSum{Name: fields[1], Size: size, SHA256: fields[3]})
+		default:
+			return nil, fmt.Errorf("%s:%d: malformed line %q", ManifestFile, line, s.Text())
+		}
+	}
+	return m, s.Err()
+}
+
+// Check compares files in dir with m
+func (m *Manifest) Check(dir string) []error {
+	errs := make([]error, 0)
+	for _, want := range m.Files {
+		got, err := sumFile(filepath.Join(dir, want.Name))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if got.Size != want.Size {
+			errs = append(errs, fmt.Errorf("%s: size %d, manifest %d", want.Name, got.Size, want.Size))
+		} else if got.SHA256 != want.SHA256 {
+			errs = append(errs, fmt.Errorf("%s: checksum mismatch", want.Name))
+		}
+	}
+	return errs
+}
+
+func sumFile(path string) (FileSum, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return FileSum{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return FileSum{}, err
+	}
+	return FileSum{Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
@@ -0,0 +1,103 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package ngtfile reads and writes the files NGT stores in an index directory.
+//
+// obj and grp are repositories laid out in little endian as
+//	size uint64 | size * ('-' | '+' entry)
+// where '-' marks a removed slot, entry of obj is the raw object and
+// entry of grp is
+//	count uint32 | count * (id uint32 | distance float32)
+package ngtfile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Property is the content of prf file
+type Property map[string]string
+
+const (
+	// PropertyFile is the name of property file
+	PropertyFile = "prf"
+	// ObjectFile is the name of object repository file
+	ObjectFile = "obj"
+	// GraphFile is the name of graph repository file
+	GraphFile = "grp"
+	// TreeFile is the name of tree file
+	TreeFile = "tre"
+
+	removedSlot = '-'
+	liveSlot    = '+'
+)
+
+var (
+	// Files are the files NGT stores in an index directory
+	Files = []string{GraphFile, ObjectFile, PropertyFile, TreeFile}
+
+	// ErrBrokenRepository raises when a slot marker is neither '-' nor '+'
+	// or a count in the file exceeds its size
+	ErrBrokenRepository = errors.New("broken repository")
+)
+
+// ReadProperty reads prf file in dir
+func ReadProperty(dir string) (Property, error) {
+	f, err := os.Open(filepath.Join(dir, PropertyFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	prop := make(Property)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		kv := strings.SplitN(s.Text(), "\t", 2)
+		if len(kv) == 2 {
+			prop[kv[0]] = kv[1]
+		}
+	}
+	return prop, s.Err()
+}
+
+// Dimension returns Dimension of the index
+func (p Property) Dimension() (int, error) {
+	dim, err := strconv.Atoi(p["Dimension"])
+	if err != nil || dim <= 0 {
+		return 0, fmt.Errorf("invalid Dimension %q", p["Dimension"])
+	}
+	return dim, nil
+}
+
+// ObjectSize returns byte size of each object in obj file
+func (p Property) ObjectSize() (int, error) {
+	dim, err := p.Dimension()
+	if err != nil {
+		return 0, err
+	}
+	switch p["ObjectType"] {
+	case "Integer-1":
+		return dim, nil
+	case "Float-4":
+		return dim * 4, nil
+	}
+	return 0, fmt.Errorf("invalid ObjectType %q", p["ObjectType"])
+}
@@ -0,0 +1,364 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package ngtfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const (
+	index   = "../../assets/test/index"
+	example = "../../assets/example"
+)
+
+func copyIndex(t *testing.T, src string) string {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Fatalf("Unexpected error: copyIndex(%v)", err)
+	}
+	for _, name := range Files {
+		b, err := ioutil.ReadFile(filepath.Join(src, name))
+		if err != nil {
+			t.Fatalf("Unexpected error: copyIndex(%v)", err)
+		}
+		if err = ioutil.WriteFile(filepath.Join(tmpdir, name), b, 0644); err != nil {
+			t.Fatalf("Unexpected error: copyIndex(%v)", err)
+		}
+	}
+	return tmpdir
+}
+
+func TestReadProperty(t *testing.T) {
+	tests := []struct {
+		dir  string
+		dim  int
+		size int
+	}{
+		{index, 6, 6},
+		{example, 128, 128},
+	}
+	for _, tt := range tests {
+		prop, err := ReadProperty(tt.dir)
+		if err != nil {
+			t.Errorf("Unexpected error: TestReadProperty(%v)", err)
+		}
+		if dim, err := prop.Dimension(); err != nil || dim != tt.dim {
+			t.Errorf("TestReadProperty(%v): %v %v, wanted: %v", tt.dir, dim, err, tt.dim)
+		}
+		if size, err := prop.ObjectSize(); err != nil || size != tt.size {
+			t.Errorf("TestReadProperty(%v): %v %v, wanted: %v", tt.dir, size, err, tt.size)
+		}
+	}
+}
+
+func TestReadObjects(t *testing.T) {
+	want := map[uint32][]byte{
+		1: {1, 0, 0, 0, 0, 0},
+		2: {0, 1, 0, 0, 0, 0},
+		3: {0, 0, 1, 0, 0, 0},
+		4: {0, 0, 0, 1, 0, 0},
+		5: {0, 0, 0, 0, 1, 0},
+		6: {1, 1, 0, 0, 0, 0},
+	}
+	f, err := os.Open(filepath.Join(index, ObjectFile))
+	if err != nil {
+		t.Fatalf("Unexpected error: TestReadObjects(%v)", err)
+	}
+	defer f.Close()
+
+	got := make(map[uint32][]byte)
+	size, err := ReadObjects(f, 6, func(id uint32, data []byte) error {
+		got[id] = append([]byte(nil), data...)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: TestReadObjects(%v)", err)
+	}
+	if size != 7 {
+		t.Errorf("TestReadObjects: size %v, wanted: %v", size, 7)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TestReadObjects: %v, wanted: %v", got, want)
+	}
+
+	size, err = ReadRepositorySize(filepath.Join(index, ObjectFile))
+	if err != nil || size != 7 {
+		t.Errorf("TestReadObjects: ReadRepositorySize %v %v, wanted: %v", size, err, 7)
+	}
+}
+
+func TestReadGraph(t *testing.T) {
+	tests := []struct {
+		dir  string
+		size int
+	}{
+		{index, 6},
+		{example, 5000},
+	}
+	for _, tt := range tests {
+		b, err := ioutil.ReadFile(filepath.Join(tt.dir, GraphFile))
+		if err != nil {
+			t.Fatalf("Unexpected error: TestReadGraph(%v)", err)
+		}
+		g, err := ReadGraph(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("Unexpected error: TestReadGraph(%v)", err)
+			continue
+		}
+		if g.Len() != tt.size {
+			t.Errorf("TestReadGraph(%v): %v, wanted: %v", tt.dir, g.Len(), tt.size)
+		}
+		if unreachable := g.Unreachable(); len(unreachable) > 0 {
+			t.Errorf("TestReadGraph(%v): unreachable %v", tt.dir, unreachable)
+		}
+
+		buf := new(bytes.Buffer)
+		if _, err = g.WriteTo(buf); err != nil {
+			t.Errorf("Unexpected error: TestReadGraph(%v)", err)
+		}
+		if !bytes.Equal(buf.Bytes(), b) {
+			t.Errorf("TestReadGraph(%v): written graph differs from original", tt.dir)
+		}
+	}
+}
+
+func TestReadGraphCorrupt(t *testing.T) {
+	header := func(size uint64, rest ...byte) []byte {
+		b := make([]byte, 8, 8+len(rest))
+		binary.LittleEndian.PutUint64(b, size)
+		return append(b, rest...)
+	}
+	tests := []struct {
+		name string
+		b    []byte
+	}{
+		{"nodes", header(1 << 62)},
+		{"edges", header(1, liveSlot, 0xff, 0xff, 0xff, 0xff)},
+		{"truncated", header(2, liveSlot, 1, 0, 0, 0)},
+	}
+	for _, tt := range tests {
+		if _, err := ReadGraph(bytes.NewReader(tt.b)); !errors.Is(err, ErrBrokenRepository) {
+			t.Errorf("TestReadGraphCorrupt(%v): %v, wanted: %v", tt.name, err, ErrBrokenRepository)
+		}
+		// counts cannot be checked without the size, reading fails at the end
+		if _, err := ReadGraph(bytes.NewBuffer(tt.b)); err != io.ErrUnexpectedEOF {
+			t.Errorf("TestReadGraphCorrupt(%v, unsized): %v, wanted: %v", tt.name, err, io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+func TestManifest(t *testing.T) {
+	tmpdir := copyIndex(t, index)
+	defer os.RemoveAll(tmpdir)
+
+	if err := WriteManifest(tmpdir, "v0.0.0", "0.0.0", 6); err != nil {
+		t.Fatalf("Unexpected error: TestManifest(%v)", err)
+	}
+	m, err := ReadManifest(tmpdir)
+	if err != nil {
+		t.Fatalf("Unexpected error: TestManifest(%v)", err)
+	}
+	if m.GongtVersion != "v0.0.0" || m.NGTVersion != "0.0.0" || m.Objects != 6 || len(m.Files) != len(Files) {
+		t.Errorf("TestManifest: %v", m)
+	}
+	if errs := m.Check(tmpdir); len(errs) > 0 {
+		t.Errorf("Unexpected error: TestManifest(%v)", errs)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(tmpdir, TreeFile))
+	if err != nil {
+		t.Fatalf("Unexpected error: TestManifest(%v)", err)
+	}
+	b[len(b)-1] ^= 0xff
+	if err = ioutil.WriteFile(filepath.Join(tmpdir, TreeFile), b, 0644); err != nil {
+		t.Fatalf("Unexpected error: TestManifest(%v)", err)
+	}
+	if errs := m.Check(tmpdir); len(errs) != 1 {
+		t.Errorf("TestManifest: %v, wanted one checksum mismatch", errs)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	for _, dir := range []string{index, example} {
+		if errs := Verify(dir); len(errs) > 0 {
+			t.Errorf("Unexpected error: TestVerify(%v)", errs)
+		}
+	}
+
+	tmpdir := copyIndex(t, index)
+	defer os.RemoveAll(tmpdir)
+	if err := WriteManifest(tmpdir, "v0.0.0", "0.0.0", 6); err != nil {
+		t.Fatalf("Unexpected error: TestVerify(%v)", err)
+	}
+	if err := os.Truncate(filepath.Join(tmpdir, ObjectFile), 30); err != nil {
+		t.Fatalf("Unexpected error: TestVerify(%v)", err)
+	}
+	// size mismatch and truncated obj
+	if errs := Verify(tmpdir); len(errs) != 2 {
+		t.Errorf("TestVerify: %v, wanted 2 problems", errs)
+	}
+
+	// the saving index held another number of objects
+	tmpdir = copyIndex(t, index)
+	defer os.RemoveAll(tmpdir)
+	if err := WriteManifest(tmpdir, "v0.0.0", "0.0.0", 7); err != nil {
+		t.Fatalf("Unexpected error: TestVerify(%v)", err)
+	}
+	if errs := Verify(tmpdir); len(errs) != 1 {
+		t.Errorf("TestVerify: %v, wanted object count mismatch", errs)
+	}
+
+	// unreachable nodes are a warning
+	tmpdir = copyIndex(t, index)
+	defer os.RemoveAll(tmpdir)
+	path := filepath.Join(tmpdir, GraphFile)
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: TestVerify(%v)", err)
+	}
+	g, err := ReadGraph(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Unexpected error: TestVerify(%v)", err)
+	}
+	for _, node := range g.Nodes {
+		if node != nil {
+			node.Edges = nil
+			break
+		}
+	}
+	buf := &bytes.Buffer{}
+	if _, err = g.WriteTo(buf); err != nil {
+		t.Fatalf("Unexpected error: TestVerify(%v)", err)
+	}
+	if err = ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Unexpected error: TestVerify(%v)", err)
+	}
+	errs := Verify(tmpdir)
+	if len(errs) != 1 {
+		t.Fatalf("TestVerify: %v, wanted one warning", errs)
+	}
+	if _, ok := errs[0].(*Warning); !ok {
+		t.Errorf("TestVerify: %v, wanted: warning", errs[0])
+	}
+}
+
+func TestOptimizeGraph(t *testing.T) {
+	g := &Graph{
+		Nodes: []*Node{
+			nil,
+			{Edges: []Edge{{2, 1}, {3, 2}, {4, 3}}},
+			{Edges: []Edge{{1, 1}, {3, 1.5}}},
+			{Edges: []Edge{{2, 1.5}, {1, 2}}},
+			// edge to removed node 5 is dropped
+			{Edges: []Edge{{1, 3}, {5, 0.5}}},
+			nil,
+		},
+	}
+	tests := []struct {
+		name string
+		got  *Graph
+		want []*Node
+	}{
+		{
+			"Reconstruct(1, 1)",
+			g.Reconstruct(1, 1),
+			[]*Node{
+				nil,
+				{Edges: []Edge{{2, 1}, {4, 3}}},
+				{Edges: []Edge{{1, 1}, {3, 1.5}}},
+				{Edges: []Edge{{2, 1.5}}},
+				{Edges: []Edge{{1, 3}}},
+				nil,
+			},
+		},
+		{
+			"Reconstruct(0, 0)",
+			g.Reconstruct(0, 0),
+			[]*Node{
+				nil,
+				{Edges: []Edge{{2, 1}, {3, 2}, {4, 3}}},
+				{Edges: []Edge{{1, 1}, {3, 1.5}}},
+				{Edges: []Edge{{2, 1.5}, {1, 2}}},
+				{Edges: []Edge{{1, 3}}},
+				nil,
+			},
+		},
+		{
+			"PrunePaths()",
+			g.PrunePaths(),
+			[]*Node{
+				nil,
+				{Edges: []Edge{{2, 1}, {4, 3}}},
+				{Edges: []Edge{{1, 1}, {3, 1.5}}},
+				{Edges: []Edge{{2, 1.5}}},
+				{Edges: []Edge{{1, 3}}},
+				nil,
+			},
+		},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got.Nodes, tt.want) {
+			t.Errorf("TestOptimizeGraph(%v): %v, wanted: %v", tt.name, tt.got.Nodes, tt.want)
+		}
+	}
+	if len(g.Nodes[4].Edges) != 2 {
+		t.Errorf("TestOptimizeGraph: source graph is modified")
+	}
+}
+
+func TestShardManifest(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Fatalf("Unexpected error: TestShardManifest(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	want := &ShardManifest{GongtVersion: "v0.0.0", Dirs: []string{"shard-000", "shard-001"}}
+	if err := WriteShardManifest(tmpdir, want); err != nil {
+		t.Fatalf("Unexpected error: TestShardManifest(%v)", err)
+	}
+	got, err := ReadShardManifest(tmpdir)
+	if err != nil {
+		t.Fatalf("Unexpected error: TestShardManifest(%v)", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TestShardManifest: %v, wanted: %v", got, want)
+	}
+
+	tests := []string{
+		"Shards\t2\nShard\t0\tshard-000\n",
+		"Shards\t1\nShard\t1\tshard-001\n",
+		"Shards\t0\n",
+		"Shard\t0\n",
+	}
+	for _, tt := range tests {
+		if err := ioutil.WriteFile(filepath.Join(tmpdir, ShardManifestFile), []byte(tt), 0644); err != nil {
+			t.Fatalf("Unexpected error: TestShardManifest(%v)", err)
+		}
+		if _, err := ReadShardManifest(tmpdir); err == nil {
+			t.Errorf("TestShardManifest(%q): no error, wanted: error", tt)
+		}
+	}
+}
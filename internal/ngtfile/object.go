@@ -0,0 +1,80 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package ngtfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+// ReadRepositorySize returns the number of slots in repository file,
+// including slot 0 which NGT never uses.
+func ReadRepositorySize(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var size uint64
+	if err = binary.Read(f, binary.LittleEndian, &size); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// ReadObjects calls fn with every live object in obj file and returns the number of slots.
+// data is reused between calls.
+func ReadObjects(r io.Reader, objectSize int, fn func(id uint32, data []byte) error) (uint64, error) {
+	br := bufio.NewReader(r)
+	var size uint64
+	if err := binary.Read(br, binary.LittleEndian, &size); err != nil {
+		return 0, err
+	}
+	data := make([]byte, objectSize)
+	for id := uint64(0); id < size; id++ {
+		mark, err := br.ReadByte()
+		if err != nil {
+			return size, unexpected(err)
+		}
+		switch mark {
+		case removedSlot:
+			continue
+		case liveSlot:
+		default:
+			return size, ErrBrokenRepository
+		}
+		if _, err = io.ReadFull(br, data); err != nil {
+			return size, unexpected(err)
+		}
+		if fn != nil {
+			if err = fn(uint32(id), data); err != nil {
+				return size, err
+			}
+		}
+	}
+	return size, nil
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
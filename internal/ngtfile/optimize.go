@@ -0,0 +1,146 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package ngtfile
+
+import (
+	"sort"
+)
+
+// Reconstruct returns a graph with adjusted edges as NGT does to make ONNG from ANNG.
+// Every node keeps its nearest outgoing edges, then for each node u the nearest
+// incoming edges u->v are added to v as reverse edges v->u.
+// outgoing <= 0 keeps every outgoing edge and incoming <= 0 adds no reverse edge.
+// Edges to removed nodes are dropped.
+func (g *Graph) Reconstruct(outgoing, incoming int) *Graph {
+	ng := &Graph{
+		Nodes:   make([]*Node, len(g.Nodes)),
+		Trailer: g.Trailer,
+	}
+	for id, node := range g.Nodes {
+		if node != nil {
+			ng.Nodes[id] = &Node{Edges: make([]Edge, 0, len(node.Edges))}
+		}
+	}
+
+	for id, node := range g.Nodes {
+		if node == nil {
+			continue
+		}
+		edges := g.liveEdges(node)
+		out := edges
+		if outgoing > 0 && len(out) > outgoing {
+			out = out[:outgoing]
+		}
+		ng.Nodes[id].Edges = append(ng.Nodes[id].Edges, out...)
+
+		in := edges
+		if incoming <= 0 {
+			in = nil
+		} else if len(in) > incoming {
+			in = in[:incoming]
+		}
+		for _, e := range in {
+			rev := ng.Nodes[e.ID]
+			rev.Edges = append(rev.Edges, Edge{ID: uint32(id), Distance: e.Distance})
+		}
+	}
+
+	for _, node := range ng.Nodes {
+		if node != nil {
+			node.Edges = dedupe(node.Edges)
+		}
+	}
+	return ng
+}
+
+// PrunePaths returns a graph without redundant edges as NGT path adjustment does.
+// An edge v->w is removed when a nearer neighbor x of v has an edge x->w shorter than v->w,
+// since w is still reached through x.
+func (g *Graph) PrunePaths() *Graph {
+	dists := make([]map[uint32]float32, len(g.Nodes))
+	for id, node := range g.Nodes {
+		if node == nil {
+			continue
+		}
+		m := make(map[uint32]float32, len(node.Edges))
+		for _, e := range node.Edges {
+			m[e.ID] = e.Distance
+		}
+		dists[id] = m
+	}
+
+	ng := &Graph{
+		Nodes:   make([]*Node, len(g.Nodes)),
+		Trailer: g.Trailer,
+	}
+	for id, node := range g.Nodes {
+		if node == nil {
+			continue
+		}
+		edges := g.liveEdges(node)
+		kept := make([]Edge, 0, len(edges))
+		for _, e := range edges {
+			redundant := false
+			for _, k := range kept {
+				if d, ok := dists[k.ID][e.ID]; ok && k.Distance < e.Distance && d < e.Distance {
+					redundant = true
+					break
+				}
+			}
+			if !redundant {
+				kept = append(kept, e)
+			}
+		}
+		ng.Nodes[id] = &Node{Edges: kept}
+	}
+	return ng
+}
+
+// liveEdges returns edges of node to live nodes sorted by distance.
+func (g *Graph) liveEdges(node *Node) []Edge {
+	edges := make([]Edge, 0, len(node.Edges))
+	for _, e := range node.Edges {
+		if int(e.ID) < len(g.Nodes) && g.Nodes[e.ID] != nil {
+			edges = append(edges, e)
+		}
+	}
+	sortEdges(edges)
+	return edges
+}
+
+// dedupe sorts edges by distance and keeps the nearest edge to each node.
+func dedupe(edges []Edge) []Edge {
+	sortEdges(edges)
+	seen := make(map[uint32]bool, len(edges))
+	ret := edges[:0]
+	for _, e := range edges {
+		if !seen[e.ID] {
+			seen[e.ID] = true
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+func sortEdges(edges []Edge) {
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].Distance != edges[j].Distance {
+			return edges[i].Distance < edges[j].Distance
+		}
+		return edges[i].ID < edges[j].ID
+	})
+}
@@ -0,0 +1,98 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package ngtfile
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ShardManifest records the layout of a sharded index directory.
+// It is stored in the same tab separated format as prf:
+//	GongtVersion	v1.1.1
+//	Shards	2
+//	Shard	0	shard-000
+//	Shard	1	shard-001
+// where each Shard line is the number and subdirectory of a shard.
+type ShardManifest struct {
+	GongtVersion string
+	Dirs         []string
+}
+
+// ShardManifestFile is the name of shard manifest file
+const ShardManifestFile = "shards"
+
+// WriteShardManifest writes shard manifest file in dir
+func WriteShardManifest(dir string, m *ShardManifest) error {
+	f, err := os.Create(filepath.Join(dir, ShardManifestFile))
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "GongtVersion\t%s\n", m.GongtVersion)
+	fmt.Fprintf(w, "Shards\t%d\n", len(m.Dirs))
+	for i, d := range m.Dirs {
+		fmt.Fprintf(w, "Shard\t%d\t%s\n", i, d)
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadShardManifest reads shard manifest file in dir
+func ReadShardManifest(dir string) (*ShardManifest, error) {
+	f, err := os.Open(filepath.Join(dir, ShardManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := new(ShardManifest)
+	shards := -1
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		fields := strings.Split(s.Text(), "\t")
+		switch {
+		case fields[0] == "GongtVersion" && len(fields) == 2:
+			m.GongtVersion = fields[1]
+		case fields[0] == "Shards" && len(fields) == 2:
+			if shards, err = strconv.Atoi(fields[1]); err != nil || shards <= 0 {
+				return nil, fmt.Errorf("%s:%d: bad number of shards %q", ShardManifestFile, line, fields[1])
+			}
+		case fields[0] == "Shard" && len(fields) == 3:
+			if i, err := strconv.Atoi(fields[1]); err != nil || i != len(m.Dirs) {
+				return nil, fmt.Errorf("%s:%d: shard %q out of order", ShardManifestFile, line, fields[1])
+			}
+			m.Dirs = append(m.Dirs, fields[2])
+		default:
+			return nil, fmt.Errorf("%s:%d: malformed line %q", ShardManifestFile, line, s.Text())
+		}
+	}
+	if err = s.Err(); err != nil {
+		return nil, err
+	}
+	if shards != len(m.Dirs) {
+		return nil, fmt.Errorf("%s: %d shards listed, header %d", ShardManifestFile, len(m.Dirs), shards)
+	}
+	return m, nil
+}
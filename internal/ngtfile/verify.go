@@ -0,0 +1,119 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package ngtfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Warning is a problem found by Verify which may not break the index
+type Warning struct {
+	Msg string
+}
+
+func (w *Warning) Error() string {
+	return "warning: " + w.Msg
+}
+
+// Verify checks files in index directory and returns the problems found.
+// Checksums and the number of objects are compared when manifest file exists.
+func Verify(dir string) []error {
+	errs := make([]error, 0)
+
+	m, err := ReadManifest(dir)
+	switch {
+	case err == nil:
+		errs = append(errs, m.Check(dir)...)
+	case !os.IsNotExist(err):
+		errs = append(errs, err)
+	}
+
+	prop, err := ReadProperty(dir)
+	if err != nil {
+		return append(errs, err)
+	}
+	objectSize, err := prop.ObjectSize()
+	if err != nil {
+		return append(errs, fmt.Errorf("%s: %v", PropertyFile, err))
+	}
+
+	f, err := os.Open(filepath.Join(dir, ObjectFile))
+	if err != nil {
+		return append(errs, err)
+	}
+	objects := make([]bool, 0)
+	live := 0
+	_, err = ReadObjects(f, objectSize, func(id uint32, _ []byte) error {
+		for len(objects) <= int(id) {
+			objects = append(objects, false)
+		}
+		objects[id] = true
+		live++
+		return nil
+	})
+	f.Close()
+	if err != nil {
+		return append(errs, fmt.Errorf("%s: %v", ObjectFile, err))
+	}
+	if m != nil && m.Objects >= 0 && live != m.Objects {
+		errs = append(errs, fmt.Errorf("%s: %d objects, manifest %d", ObjectFile, live, m.Objects))
+	}
+
+	f, err = os.Open(filepath.Join(dir, GraphFile))
+	if err != nil {
+		return append(errs, err)
+	}
+	g, err := ReadGraph(f)
+	f.Close()
+	if err != nil {
+		return append(errs, fmt.Errorf("%s: %v", GraphFile, err))
+	}
+
+	unindexed := 0
+	for id, ok := range objects {
+		if ok && (id >= len(g.Nodes) || g.Nodes[id] == nil) {
+			unindexed++
+		}
+	}
+	// objects inserted but not indexed before saving are valid
+	if unindexed > 0 {
+		errs = append(errs, &Warning{fmt.Sprintf("%s: %d objects have no node", GraphFile, unindexed)})
+	}
+	dangling := 0
+	for _, node := range g.Nodes {
+		if node == nil {
+			continue
+		}
+		for _, e := range node.Edges {
+			if int(e.ID) >= len(objects) || !objects[e.ID] {
+				dangling++
+			}
+		}
+	}
+	if dangling > 0 {
+		errs = append(errs, fmt.Errorf("%s: %d edges point to missing objects", GraphFile, dangling))
+	}
+	// NGT seeds searches from its tree, which is not read here,
+	// so nodes unreachable from one node may still be found
+	if unreachable := g.Unreachable(); len(unreachable) > 0 {
+		errs = append(errs, &Warning{fmt.Sprintf("%s: %d nodes are unreachable from the first node", GraphFile, len(unreachable))})
+	}
+
+	return errs
+}
@@ -0,0 +1,209 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package loader provides streaming readers for vector files to build gongt index.
+//
+//	f, err := loader.Open("vectors.fvecs")
+//	if err != nil {
+//		return err
+//	}
+//	defer f.Close()
+//	n := gongt.New(path).SetObjectType(gongt.ObjectType(f.ObjectType())).SetDimension(f.Dimension()).Open()
+//	ids, errs := loader.Load(n, f, gongt.DefaultBulkInsertChunkSize)
+package loader
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// Reader reads vectors one by one
+	Reader interface {
+		// Read returns the next vector and io.EOF at the end of input.
+		// Malformed vectors are reported as *ParseError and skipped.
+		Read() ([]float64, error)
+		// Dimension returns dimension detected from the first vector
+		Dimension() int
+		// ObjectType returns object type detected from the input
+		ObjectType() ObjectType
+	}
+	// ReadCloser is Reader reading a file
+	ReadCloser interface {
+		Reader
+		io.Closer
+	}
+	// Inserter is implemented by *gongt.NGT
+	Inserter interface {
+		BulkInsert(vecs [][]float64) ([]int, []error)
+	}
+	// ParseError reports malformed vector
+	ParseError struct {
+		// Line is line number for text formats and record number for binary formats, 1-origin
+		Line int
+		Err  error
+	}
+	// ObjectType has the same values as gongt.ObjectType
+	ObjectType int
+	// Format is vector file format
+	Format int
+
+	readCloser struct {
+		Reader
+		io.Closer
+	}
+)
+
+// MaxDimension is the largest dimension binary readers accept,
+// a larger dimension in a header is treated as corruption
+const MaxDimension = 1 << 20
+
+const (
+	// ObjectNone is unknown object type
+	ObjectNone ObjectType = iota
+	// Uint8 is 8bit unsigned integer
+	Uint8
+	// Float is 32bit floating point number
+	Float
+)
+
+const (
+	// FormatNone is unknown format
+	FormatNone Format = iota
+	// Fvecs is int32 dimension followed by float32 values for each vector
+	Fvecs
+	// Bvecs is int32 dimension followed by uint8 values for each vector
+	Bvecs
+	// Ivecs is int32 dimension followed by int32 values for each vector
+	Ivecs
+	// Npy is NumPy .npy array
+	Npy
+	// CSV is comma separated values
+	CSV
+	// TSV is tab separated values
+	TSV
+	// SSV is space separated values
+	SSV
+	// JSONL is JSON Lines of arrays or objects with "vector" field
+	JSONL
+)
+
+var extensions = map[string]Format{
+	".fvecs":  Fvecs,
+	".bvecs":  Bvecs,
+	".ivecs":  Ivecs,
+	".npy":    Npy,
+	".csv":    CSV,
+	".tsv":    TSV,
+	".ssv":    SSV,
+	".txt":    SSV,
+	".jsonl":  JSONL,
+	".ndjson": JSONL,
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// DetectFormat returns Format from file extension
+func DetectFormat(path string) Format {
+	return extensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// NewReader returns Reader reading format from r
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	switch format {
+	case Fvecs:
+		return NewFvecsReader(r)
+	case Bvecs:
+		return NewBvecsReader(r)
+	case Ivecs:
+		return NewIvecsReader(r)
+	case Npy:
+		return NewNpyReader(r)
+	case CSV:
+		return NewTextReader(r, ',')
+	case TSV:
+		return NewTextReader(r, '\t')
+	case SSV:
+		return NewTextReader(r, ' ')
+	case JSONL:
+		return NewJSONLReader(r)
+	}
+	return nil, fmt.Errorf("Unknown format %d", format)
+}
+
+// Open returns ReadCloser reading path, format is detected from extension
+func Open(path string) (ReadCloser, error) {
+	format := DetectFormat(path)
+	if format == FormatNone {
+		return nil, fmt.Errorf("%s: unknown file extension", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(f, format)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return readCloser{r, f}, nil
+}
+
+// Load reads every vector from r and inserts them into ins in chunks of chunkSize.
+// Malformed vectors are skipped and reported in errs, this only stores not indexing,
+// you must call CreateIndex and SaveIndex.
+func Load(ins Inserter, r Reader, chunkSize int) ([]int, []error) {
+	if chunkSize <= 0 {
+		chunkSize = 1
+	}
+	ids := make([]int, 0, chunkSize)
+	errs := make([]error, 0)
+	chunk := make([][]float64, 0, chunkSize)
+	flush := func() {
+		if len(chunk) == 0 {
+			return
+		}
+		cids, cerrs := ins.BulkInsert(chunk)
+		ids = append(ids, cids...)
+		errs = append(errs, cerrs...)
+		chunk = chunk[:0]
+	}
+
+	for {
+		vec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, err)
+			if _, ok := err.(*ParseError); ok {
+				continue
+			}
+			break
+		}
+		chunk = append(chunk, vec)
+		if len(chunk) >= chunkSize {
+			flush()
+		}
+	}
+	flush()
+	return ids, errs
+}
@@ -0,0 +1,223 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package loader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var vectors = [][]float64{
+	{1, 0, 0, 0, 0, 0},
+	{0, 1, 0, 0, 0, 0},
+	{0, 0, 1, 0, 0, 0},
+	{0, 0, 0, 1, 0, 0},
+	{0, 0, 0, 0, 1, 0},
+	{1, 1, 0, 0, 0, 0},
+}
+
+type inserter struct {
+	vecs [][]float64
+}
+
+func (ins *inserter) BulkInsert(vecs [][]float64) ([]int, []error) {
+	ids := make([]int, len(vecs))
+	for i, vec := range vecs {
+		ins.vecs = append(ins.vecs, vec)
+		ids[i] = len(ins.vecs)
+	}
+	return ids, nil
+}
+
+func readAll(t *testing.T, r Reader) ([][]float64, []error) {
+	vecs := make([][]float64, 0)
+	errs := make([]error, 0)
+	for {
+		vec, err := r.Read()
+		if err == io.EOF {
+			return vecs, errs
+		}
+		if err != nil {
+			errs = append(errs, err)
+			if _, ok := err.(*ParseError); !ok {
+				return vecs, errs
+			}
+			continue
+		}
+		vecs = append(vecs, vec)
+	}
+}
+
+func vecs(size int, put func(b []byte, v float64)) []byte {
+	buf := new(bytes.Buffer)
+	for _, vec := range vectors {
+		binary.Write(buf, binary.LittleEndian, int32(len(vec)))
+		b := make([]byte, size)
+		for _, v := range vec {
+			put(b, v)
+			buf.Write(b)
+		}
+	}
+	return buf.Bytes()
+}
+
+func npy(descr string, size int, put func(b []byte, v float64)) []byte {
+	header := "{'descr': '" + descr + "', 'fortran_order': False, 'shape': (6, 6), }"
+	header += strings.Repeat(" ", 63-(10+len(header))%64) + "\n"
+	buf := bytes.NewBufferString("\x93NUMPY\x01\x00")
+	binary.Write(buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+	b := make([]byte, size)
+	for _, vec := range vectors {
+		for _, v := range vec {
+			put(b, v)
+			buf.Write(b)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestReaders(t *testing.T) {
+	putFloat32 := func(b []byte, v float64) { binary.LittleEndian.PutUint32(b, math.Float32bits(float32(v))) }
+	putUint8 := func(b []byte, v float64) { b[0] = uint8(v) }
+
+	tests := []struct {
+		format Format
+		input  []byte
+		ot     ObjectType
+	}{
+		{Fvecs, vecs(4, putFloat32), Float},
+		{Bvecs, vecs(1, putUint8), Uint8},
+		{Ivecs, vecs(4, func(b []byte, v float64) { binary.LittleEndian.PutUint32(b, uint32(v)) }), Float},
+		{Npy, npy("<f4", 4, putFloat32), Float},
+		{Npy, npy("|u1", 1, putUint8), Uint8},
+		{CSV, []byte("a,b,c,d,e,f\n1,0,0,0,0,0\n0,1,0,0,0,0\n0,0,1,0,0,0\n0,0,0,1,0,0\n0,0,0,0,1,0\n1,1,0,0,0,0\n"), Float},
+		{TSV, []byte("1\t0\t0\t0\t0\t0\n0\t1\t0\t0\t0\t0\n0\t0\t1\t0\t0\t0\n0\t0\t0\t1\t0\t0\n0\t0\t0\t0\t1\t0\n1\t1\t0\t0\t0\t0\n"), Float},
+		{SSV, []byte("1 0 0 0 0 0\n0 1 0 0 0 0\n0 0 1 0 0 0\n0 0 0 1 0 0\n0 0 0 0 1 0\n1 1 0 0 0 0\n"), Float},
+		{JSONL, []byte("[1,0,0,0,0,0]\n{\"vector\":[0,1,0,0,0,0]}\n[0,0,1,0,0,0]\n\n[0,0,0,1,0,0]\n[0,0,0,0,1,0]\n[1,1,0,0,0,0]\n"), Float},
+	}
+	for _, tt := range tests {
+		r, err := NewReader(bytes.NewReader(tt.input), tt.format)
+		if err != nil {
+			t.Errorf("Unexpected error: TestReaders(%v)", err)
+			continue
+		}
+		if r.Dimension() != 6 {
+			t.Errorf("TestReaders(%v): dimension %v, wanted: %v", tt.format, r.Dimension(), 6)
+		}
+		if r.ObjectType() != tt.ot {
+			t.Errorf("TestReaders(%v): object type %v, wanted: %v", tt.format, r.ObjectType(), tt.ot)
+		}
+		got, errs := readAll(t, r)
+		if len(errs) > 0 {
+			t.Errorf("Unexpected error: TestReaders(%v)", errs)
+		}
+		if !reflect.DeepEqual(got, vectors) {
+			t.Errorf("TestReaders(%v): %v, wanted: %v", tt.format, got, vectors)
+		}
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	tests := []struct {
+		format Format
+		input  string
+		lines  []int
+	}{
+		{SSV, "1 0 0\n0 x 0\n0 0 1\n1 1\n", []int{2, 4}},
+		{SSV, "1 x 0\n\n0 y\n0 0 1\n1 1\n", []int{1, 3, 5}},
+		{CSV, "1,0,0\n\n0,0,1,1\n", []int{3}},
+		{JSONL, "[1,0,0]\n{\"v\":[1]}\n[0,0\n", []int{2, 3}},
+		{JSONL, "[]\n[1,0,0]\n", []int{1}},
+	}
+	for _, tt := range tests {
+		r, err := NewReader(strings.NewReader(tt.input), tt.format)
+		if err != nil {
+			t.Errorf("Unexpected error: TestMalformed(%v)", err)
+			continue
+		}
+		_, errs := readAll(t, r)
+		lines := make([]int, 0, len(errs))
+		for _, err := range errs {
+			if perr, ok := err.(*ParseError); ok {
+				lines = append(lines, perr.Line)
+			}
+		}
+		if !reflect.DeepEqual(lines, tt.lines) {
+			t.Errorf("TestMalformed(%v): %v, wanted: %v", tt.format, errs, tt.lines)
+		}
+	}
+}
+
+func TestCorruptHeader(t *testing.T) {
+	dim := func(d int32) []byte {
+		buf := new(bytes.Buffer)
+		binary.Write(buf, binary.LittleEndian, d)
+		return buf.Bytes()
+	}
+	npyShape := func(shape string) []byte {
+		header := "{'descr': '<f4', 'fortran_order': False, 'shape': " + shape + ", }\n"
+		buf := bytes.NewBufferString("\x93NUMPY\x01\x00")
+		binary.Write(buf, binary.LittleEndian, uint16(len(header)))
+		buf.WriteString(header)
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		format Format
+		input  []byte
+	}{
+		{Fvecs, dim(-1)},
+		{Fvecs, dim(math.MaxInt32)},
+		{Bvecs, dim(0)},
+		{Npy, npyShape("(-1, 6)")},
+		{Npy, npyShape("(6, -6)")},
+		{Npy, npyShape("(6, 0)")},
+		{Npy, npyShape("(1, 2147483647)")},
+		{Npy, []byte("\x93NUMPY\x02\x00\xff\xff\xff\xff")},
+	}
+	for _, tt := range tests {
+		if _, err := NewReader(bytes.NewReader(tt.input), tt.format); err == nil {
+			t.Errorf("TestCorruptHeader(%v, %q): no error, wanted: error", tt.format, tt.input)
+		}
+	}
+}
+
+func TestOpenAndLoad(t *testing.T) {
+	r, err := Open("../assets/test/test.ssv")
+	if err != nil {
+		t.Fatalf("Unexpected error: TestOpenAndLoad(%v)", err)
+	}
+	defer r.Close()
+
+	ins := new(inserter)
+	ids, errs := Load(ins, r, 4)
+	if len(errs) > 0 {
+		t.Errorf("Unexpected error: TestOpenAndLoad(%v)", errs)
+	}
+	if !reflect.DeepEqual(ids, []int{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("TestOpenAndLoad: %v, wanted: %v", ids, []int{1, 2, 3, 4, 5, 6})
+	}
+	if !reflect.DeepEqual(ins.vecs, vectors) {
+		t.Errorf("TestOpenAndLoad: %v, wanted: %v", ins.vecs, vectors)
+	}
+}
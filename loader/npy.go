@@ -0,0 +1,183 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package loader
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type npyReader struct {
+	r      *bufio.Reader
+	size   int
+	decode func(b []byte) float64
+	ot     ObjectType
+	rows   int
+	dim    int
+	record int
+	buf    []byte
+}
+
+var (
+	npyMagic = []byte("\x93NUMPY")
+	// npyMaxHeader bounds header length, numpy writes a few hundred bytes
+	npyMaxHeader = 1 << 20
+
+	npyDescr   = regexp.MustCompile(`'descr'\s*:\s*'([^']*)'`)
+	npyFortran = regexp.MustCompile(`'fortran_order'\s*:\s*(True|False)`)
+	npyShape   = regexp.MustCompile(`'shape'\s*:\s*\(([^)]*)\)`)
+
+	// ErrNpyFortranOrder raises reading column major array
+	ErrNpyFortranOrder = errors.New("npy: fortran_order array is not supported")
+)
+
+// NewNpyReader returns Reader for NumPy .npy holding 1 or 2-dimensional array
+// of uint8, int32, float32 or float64 in row major order.
+func NewNpyReader(r io.Reader) (Reader, error) {
+	nr := &npyReader{r: bufio.NewReader(r)}
+
+	magic := make([]byte, 8)
+	if _, err := io.ReadFull(nr.r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic[:6]) != string(npyMagic) {
+		return nil, errors.New("npy: invalid magic")
+	}
+	var hlen int
+	switch magic[6] {
+	case 1:
+		var l uint16
+		if err := binary.Read(nr.r, binary.LittleEndian, &l); err != nil {
+			return nil, err
+		}
+		hlen = int(l)
+	case 2, 3:
+		var l uint32
+		if err := binary.Read(nr.r, binary.LittleEndian, &l); err != nil {
+			return nil, err
+		}
+		hlen = int(l)
+	default:
+		return nil, fmt.Errorf("npy: unsupported version %d", magic[6])
+	}
+	if hlen > npyMaxHeader {
+		return nil, fmt.Errorf("npy: header length %d is too large", hlen)
+	}
+	header := make([]byte, hlen)
+	if _, err := io.ReadFull(nr.r, header); err != nil {
+		return nil, err
+	}
+	if err := nr.parseHeader(string(header)); err != nil {
+		return nil, err
+	}
+	nr.buf = make([]byte, nr.dim*nr.size)
+	return nr, nil
+}
+
+func (nr *npyReader) parseHeader(header string) error {
+	m := npyFortran.FindStringSubmatch(header)
+	if m == nil {
+		return errors.New("npy: fortran_order not found")
+	}
+	if m[1] == "True" {
+		return ErrNpyFortranOrder
+	}
+
+	m = npyDescr.FindStringSubmatch(header)
+	if m == nil {
+		return errors.New("npy: descr not found")
+	}
+	switch m[1] {
+	case "|u1", "<u1", "u1":
+		nr.size, nr.ot = 1, Uint8
+		nr.decode = func(b []byte) float64 { return float64(b[0]) }
+	case "<i4":
+		nr.size, nr.ot = 4, Float
+		nr.decode = func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) }
+	case "<f4":
+		nr.size, nr.ot = 4, Float
+		nr.decode = func(b []byte) float64 { return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) }
+	case "<f8":
+		nr.size, nr.ot = 8, Float
+		nr.decode = func(b []byte) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b)) }
+	default:
+		return fmt.Errorf("npy: unsupported descr %q", m[1])
+	}
+
+	m = npyShape.FindStringSubmatch(header)
+	if m == nil {
+		return errors.New("npy: shape not found")
+	}
+	shape := make([]int, 0, 2)
+	for _, s := range strings.Split(m[1], ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("npy: invalid shape %q", m[1])
+		}
+		shape = append(shape, v)
+	}
+	switch len(shape) {
+	case 1:
+		nr.rows, nr.dim = 1, shape[0]
+	case 2:
+		nr.rows, nr.dim = shape[0], shape[1]
+	default:
+		return fmt.Errorf("npy: unsupported shape %q", m[1])
+	}
+	if nr.rows < 0 || nr.dim <= 0 || nr.dim > MaxDimension {
+		return fmt.Errorf("npy: invalid shape %q", m[1])
+	}
+	return nil
+}
+
+func (nr *npyReader) Read() ([]float64, error) {
+	if nr.record >= nr.rows {
+		return nil, io.EOF
+	}
+	nr.record++
+	if _, err := io.ReadFull(nr.r, nr.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		// rows after a short read cannot be framed
+		nr.rows = nr.record
+		return nil, &ParseError{nr.record, err}
+	}
+	vec := make([]float64, nr.dim)
+	for i := range vec {
+		vec[i] = nr.decode(nr.buf[i*nr.size:])
+	}
+	return vec, nil
+}
+
+func (nr *npyReader) Dimension() int {
+	return nr.dim
+}
+
+func (nr *npyReader) ObjectType() ObjectType {
+	return nr.ot
+}
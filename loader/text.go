@@ -0,0 +1,172 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package loader
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// lineReader reads vectors line by line, blank lines are skipped.
+type lineReader struct {
+	s     *bufio.Scanner
+	parse func(line string) ([]float64, error)
+	line  int
+	dim   int
+	next  []float64
+	// errs are malformed lines before the first vector
+	errs []error
+}
+
+// NewTextReader returns Reader for values separated by sep, one vector per line.
+// Space separator also accepts tabs and repeated spaces.
+// A first line which has no number is skipped as header.
+func NewTextReader(r io.Reader, sep rune) (Reader, error) {
+	split := func(line string) []string {
+		return strings.Split(line, string(sep))
+	}
+	if sep == ' ' {
+		split = strings.Fields
+	}
+	lr := newLineReader(r, func(line string) ([]float64, error) {
+		fields := split(line)
+		vec := make([]float64, len(fields))
+		for i, f := range fields {
+			v, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+			if err != nil {
+				return nil, fmt.Errorf("column %d: %v", i+1, err)
+			}
+			vec[i] = v
+		}
+		return vec, nil
+	})
+	if err := lr.readFirst(func(line string) bool {
+		for _, f := range split(line) {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(f), 64); err == nil {
+				return false
+			}
+		}
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	return lr, nil
+}
+
+// NewJSONLReader returns Reader for JSON Lines, each line is an array of numbers
+// or an object with "vector" field.
+func NewJSONLReader(r io.Reader) (Reader, error) {
+	lr := newLineReader(r, func(line string) ([]float64, error) {
+		var vec []float64
+		if strings.HasPrefix(line, "{") {
+			obj := struct {
+				Vector []float64 `json:"vector"`
+			}{}
+			if err := json.Unmarshal([]byte(line), &obj); err != nil {
+				return nil, err
+			}
+			if obj.Vector == nil {
+				return nil, fmt.Errorf("vector field not found")
+			}
+			vec = obj.Vector
+		} else if err := json.Unmarshal([]byte(line), &vec); err != nil {
+			return nil, err
+		}
+		return vec, nil
+	})
+	if err := lr.readFirst(nil); err != nil {
+		return nil, err
+	}
+	return lr, nil
+}
+
+func newLineReader(r io.Reader, parse func(string) ([]float64, error)) *lineReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	return &lineReader{s: s, parse: parse}
+}
+
+// readFirst reads ahead the first vector for dimension, skipping the first line if header returns true.
+// Malformed lines before it are kept to be reported by Read like the others.
+func (lr *lineReader) readFirst(header func(string) bool) error {
+	for lr.s.Scan() {
+		lr.line++
+		line := strings.TrimSpace(lr.s.Text())
+		if line == "" {
+			continue
+		}
+		if lr.line == 1 && header != nil && header(line) {
+			continue
+		}
+		vec, err := lr.parse(line)
+		if err != nil {
+			lr.errs = append(lr.errs, &ParseError{lr.line, err})
+			continue
+		}
+		if len(vec) == 0 {
+			lr.errs = append(lr.errs, &ParseError{lr.line, fmt.Errorf("empty vector")})
+			continue
+		}
+		lr.next, lr.dim = vec, len(vec)
+		return nil
+	}
+	return lr.s.Err()
+}
+
+func (lr *lineReader) Read() ([]float64, error) {
+	if len(lr.errs) > 0 {
+		err := lr.errs[0]
+		lr.errs = lr.errs[1:]
+		return nil, err
+	}
+	if lr.next != nil {
+		vec := lr.next
+		lr.next = nil
+		return vec, nil
+	}
+	for lr.s.Scan() {
+		lr.line++
+		line := strings.TrimSpace(lr.s.Text())
+		if line == "" {
+			continue
+		}
+		vec, err := lr.parse(line)
+		if err != nil {
+			return nil, &ParseError{lr.line, err}
+		}
+		if len(vec) != lr.dim {
+			return nil, &ParseError{lr.line, fmt.Errorf("dimension %d, wanted: %d", len(vec), lr.dim)}
+		}
+		return vec, nil
+	}
+	if err := lr.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (lr *lineReader) Dimension() int {
+	return lr.dim
+}
+
+func (lr *lineReader) ObjectType() ObjectType {
+	return Float
+}
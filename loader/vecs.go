@@ -0,0 +1,129 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package loader
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// vecsReader reads fvecs, bvecs and ivecs, each record is
+// int32 dimension followed by dimension values.
+type vecsReader struct {
+	r      *bufio.Reader
+	size   int
+	decode func(b []byte) float64
+	ot     ObjectType
+	dim    int
+	record int
+	buf    []byte
+	next   []float64
+	err    error
+}
+
+// NewFvecsReader returns Reader for fvecs
+func NewFvecsReader(r io.Reader) (Reader, error) {
+	return newVecsReader(r, 4, Float, func(b []byte) float64 {
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	})
+}
+
+// NewBvecsReader returns Reader for bvecs
+func NewBvecsReader(r io.Reader) (Reader, error) {
+	return newVecsReader(r, 1, Uint8, func(b []byte) float64 {
+		return float64(b[0])
+	})
+}
+
+// NewIvecsReader returns Reader for ivecs
+func NewIvecsReader(r io.Reader) (Reader, error) {
+	return newVecsReader(r, 4, Float, func(b []byte) float64 {
+		return float64(int32(binary.LittleEndian.Uint32(b)))
+	})
+}
+
+func newVecsReader(r io.Reader, size int, ot ObjectType, decode func([]byte) float64) (Reader, error) {
+	vr := &vecsReader{
+		r:      bufio.NewReader(r),
+		size:   size,
+		decode: decode,
+		ot:     ot,
+	}
+	// read ahead the first record for dimension
+	vr.next, vr.err = vr.read()
+	if vr.err != nil && vr.err != io.EOF {
+		return nil, vr.err
+	}
+	if vr.next != nil {
+		vr.dim = len(vr.next)
+	}
+	return vr, nil
+}
+
+func (vr *vecsReader) Read() ([]float64, error) {
+	if vr.next != nil || vr.err != nil {
+		vec, err := vr.next, vr.err
+		vr.next, vr.err = nil, nil
+		return vec, err
+	}
+	return vr.read()
+}
+
+func (vr *vecsReader) read() ([]float64, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(vr.r, hdr[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, &ParseError{vr.record + 1, err}
+	}
+	vr.record++
+	dim := int(int32(binary.LittleEndian.Uint32(hdr[:])))
+	if dim <= 0 || dim > MaxDimension {
+		// the rest of the input cannot be framed
+		return nil, fmt.Errorf("record %d: invalid dimension %d", vr.record, dim)
+	}
+	if cap(vr.buf) < dim*vr.size {
+		vr.buf = make([]byte, dim*vr.size)
+	}
+	buf := vr.buf[:dim*vr.size]
+	if _, err := io.ReadFull(vr.r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, &ParseError{vr.record, err}
+	}
+	if vr.dim != 0 && dim != vr.dim {
+		return nil, &ParseError{vr.record, fmt.Errorf("dimension %d, wanted: %d", dim, vr.dim)}
+	}
+	vec := make([]float64, dim)
+	for i := range vec {
+		vec[i] = vr.decode(buf[i*vr.size:])
+	}
+	return vec, nil
+}
+
+func (vr *vecsReader) Dimension() int {
+	return vr.dim
+}
+
+func (vr *vecsReader) ObjectType() ObjectType {
+	return vr.ot
+}
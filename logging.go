@@ -0,0 +1,71 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// SetLogger sets logger of the default NGT index
+func SetLogger(logger *slog.Logger) *NGT {
+	return Get().SetLogger(logger)
+}
+
+// SetLogger sets logger receiving events of NGT index, nil to disable.
+// Open, index creation and saves are logged at Info, slow searches at Warn
+// and errors of NGT at Error, each with the index path.
+//	ngt := gongt.New("index Path").SetLogger(slog.Default()).Open()
+func (n *NGT) SetLogger(logger *slog.Logger) *NGT {
+	n.mu.Lock()
+	n.logger = logger
+	n.mu.Unlock()
+
+	return n
+}
+
+// SetSlowQueryThreshold sets slow search threshold of the default NGT index
+func SetSlowQueryThreshold(d time.Duration) *NGT {
+	return Get().SetSlowQueryThreshold(d)
+}
+
+// SetSlowQueryThreshold logs searches taking d or longer, 0 to disable.
+func (n *NGT) SetSlowQueryThreshold(d time.Duration) *NGT {
+	n.mu.Lock()
+	n.prop.SlowQueryThreshold = d
+	n.mu.Unlock()
+
+	return n
+}
+
+// log writes event msg with attributes args and the index path.
+func (n *NGT) log(level slog.Level, msg string, args ...any) {
+	if n.logger == nil || !n.logger.Enabled(context.Background(), level) {
+		return
+	}
+	n.logger.Log(context.Background(), level, msg, append([]any{"path", n.prop.IndexPath}, args...)...)
+}
+
+// logSearch logs search which took elapsed if it exceeds SlowQueryThreshold.
+func (n *NGT) logSearch(elapsed time.Duration, size int, epsilon float32, results int) {
+	if t := n.prop.SlowQueryThreshold; t > 0 && elapsed >= t {
+		n.log(slog.LevelWarn, "slow search", "duration", elapsed, "size", size, "epsilon", epsilon, "results", results)
+	}
+}
@@ -0,0 +1,93 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestLogger(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	buf := new(bytes.Buffer)
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+	indexPath := filepath.Join(tmpdir, "index")
+	n := New(indexPath).SetObjectType(Uint8).SetDimension(6).
+		SetLogger(logger).SetSlowQueryThreshold(time.Nanosecond).Open()
+	defer n.Close()
+	if _, err := n.InsertCommit([]float64{1, 0, 0, 0, 0, 0}, poolSize); err != nil {
+		t.Errorf("Unexpected error: TestLogger(%v)", err)
+	}
+	if _, err := n.Search([]float64{1, 0, 0, 0, 0, 0}, 1, DefaultEpsilon); err != nil {
+		t.Errorf("Unexpected error: TestLogger(%v)", err)
+	}
+	if err := n.Remove(100); err == nil {
+		t.Errorf("TestLogger(remove): no error, wanted: error")
+	}
+
+	events := make(map[string]map[string]interface{})
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var e map[string]interface{}
+		if err := dec.Decode(&e); err != nil {
+			t.Fatalf("Unexpected error: TestLogger(%v)", err)
+		}
+		if e["path"] != indexPath {
+			t.Errorf("TestLogger(%v): path %v, wanted: %v", e["msg"], e["path"], indexPath)
+		}
+		events[e["msg"].(string)] = e
+	}
+
+	tests := []struct {
+		msg   string
+		level string
+		key   string
+	}{
+		{"created new index", "INFO", "reason"},
+		{"opened index", "INFO", "dimension"},
+		{"created index", "INFO", "duration"},
+		{"saved index", "INFO", "bytes"},
+		{"slow search", "WARN", "duration"},
+		{"NGT error", "ERROR", "error"},
+	}
+	for _, tt := range tests {
+		e, ok := events[tt.msg]
+		if !ok {
+			t.Errorf("TestLogger(%v): not logged", tt.msg)
+			continue
+		}
+		if e["level"] != tt.level {
+			t.Errorf("TestLogger(%v): %v, wanted: %v", tt.msg, e["level"], tt.level)
+		}
+		if _, ok := e[tt.key]; !ok {
+			t.Errorf("TestLogger(%v): %v, wanted with %v", tt.msg, e, tt.key)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package gongt
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MemoryIndex is Index searching objects by brute force in memory without NGT.
+// It follows NGT in object ids, object types and distances so that
+// it can stand in for NGT in tests, but it is not persisted and SaveIndex does nothing.
+// Like NGT, inserted objects are searched after CreateIndex.
+//	index := gongt.NewMemoryIndex().SetDimension(128).SetDistanceType(gongt.Cosine)
+type MemoryIndex struct {
+	mu      *sync.RWMutex
+	prop    Property
+	objects [][]float32
+	indexed []bool
+	removed []uint
+	emu     *sync.Mutex
+	errs    []error
+}
+
+var (
+	// ErrCAPINotImplemented raises using not implemented function in C API
+	ErrCAPINotImplemented = errors.New("Not implemented in C API")
+	// ErrDimension raises using vector whose length differs from the dimension of the index
+	ErrDimension = errors.New("vector length differs from dimension")
+	// ErrObjectID raises using id of object which does not exist
+	ErrObjectID = errors.New("object does not exist")
+)
+
+var _ Index = (*MemoryIndex)(nil)
+
+// NewMemoryIndex returns MemoryIndex with default object and distance types.
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		mu:  &sync.RWMutex{},
+		emu: &sync.Mutex{},
+		prop: Property{
+			ObjectType:   Float,
+			DistanceType: L2,
+		},
+		// id 0 is never used as in NGT
+		objects: make([][]float32, 1),
+		indexed: make([]bool, 1),
+	}
+}
+
+// SetDimension sets vector dimension
+func (m *MemoryIndex) SetDimension(dimension int) *MemoryIndex {
+	m.prop.Dimension = dimension
+	return m
+}
+
+// SetObjectType sets object type
+func (m *MemoryIndex) SetObjectType(ot ObjectType) *MemoryIndex {
+	m.prop.ObjectType = ot
+	return m
+}
+
+// SetDistanceType sets distance type.
+// NormalizedAngle and NormalizedCosine are rejected as NGT does, Insert fails with them.
+func (m *MemoryIndex) SetDistanceType(dt DistanceType) *MemoryIndex {
+	if dt == NormalizedAngle || dt == NormalizedCosine {
+		m.addError(fmt.Errorf("distance type %d: %w", dt, ErrCAPINotImplemented))
+	}
+	m.prop.DistanceType = dt
+	return m
+}
+
+// Insert returns object id, reusing the smallest id of removed objects as NGT does.
+// This only stores not indexing, you must call CreateIndex.
+func (m *MemoryIndex) Insert(vec []float64) (int, error) {
+	if m.prop.DistanceType == NormalizedAngle || m.prop.DistanceType == NormalizedCosine {
+		return 0, m.addError(fmt.Errorf("distance type %d: %w", m.prop.DistanceType, ErrCAPINotImplemented))
+	}
+	if err := m.validate(vec); err != nil {
+		return 0, err
+	}
+	obj := toObject(m.prop.ObjectType, vec)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.removed) > 0 {
+		id := m.removed[0]
+		m.removed = m.removed[1:]
+		m.objects[id] = obj
+		return int(id), nil
+	}
+	m.objects = append(m.objects, obj)
+	m.indexed = append(m.indexed, false)
+	return len(m.objects) - 1, nil
+}
+
+// BulkInsert returns object ids.
+// This only stores not indexing, you must call CreateIndex.
+func (m *MemoryIndex) BulkInsert(vecs [][]float64) ([]int, []error) {
+	ids := make([]int, 0, len(vecs))
+	errs := make([]error, 0, len(vecs))
+	for _, vec := range vecs {
+		if id, err := m.Insert(vec); err == nil {
+			ids = append(ids, id)
+		} else {
+			errs = append(errs, err)
+		}
+	}
+	return ids, errs
+}
+
+// CreateIndex makes inserted objects searchable, poolSize is ignored.
+func (m *MemoryIndex) CreateIndex(poolSize int) error {
+	m.mu.Lock()
+	for id, obj := range m.objects {
+		m.indexed[id] = obj != nil
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+// SaveIndex does nothing because MemoryIndex is not persisted.
+func (m *MemoryIndex) SaveIndex() error {
+	return nil
+}
+
+// Search returns the nearest size indexed objects sorted by distance and id,
+// empty if size is not positive. Search is exact, so epsilon is ignored.
+func (m *MemoryIndex) Search(vec []float64, size int, epsilon float64) ([]SearchResult, error) {
+	if err := m.validate(vec); err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		return []SearchResult{}, nil
+	}
+	query := toObject(m.prop.ObjectType, vec)
+
+	m.mu.RLock()
+	result := make([]SearchResult, 0, len(m.objects))
+	for id, obj := range m.objects {
+		if m.indexed[id] {
+			result = append(result, SearchResult{
+				ID:       id,
+				Distance: float64(distance(m.prop.DistanceType, query, obj)),
+			})
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Distance != result[j].Distance {
+			return result[i].Distance < result[j].Distance
+		}
+		return result[i].ID < result[j].ID
+	})
+	if size < len(result) {
+		result = result[:size]
+	}
+	return result, nil
+}
+
+// Remove removes object id.
+func (m *MemoryIndex) Remove(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.exists(id) {
+		return m.addError(fmt.Errorf("remove %d: %w", id, ErrObjectID))
+	}
+	m.objects[id] = nil
+	m.indexed[id] = false
+	i := sort.Search(len(m.removed), func(i int) bool { return m.removed[i] >= uint(id) })
+	m.removed = append(m.removed, 0)
+	copy(m.removed[i+1:], m.removed[i:])
+	m.removed[i] = uint(id)
+	return nil
+}
+
+// GetVector returns vector of object id as it is stored.
+func (m *MemoryIndex) GetVector(id int) ([]float64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if !m.exists(id) {
+		return nil, m.addError(fmt.Errorf("get %d: %w", id, ErrObjectID))
+	}
+	ret := make([]float64, len(m.objects[id]))
+	for i, v := range m.objects[id] {
+		ret[i] = float64(v)
+	}
+	return ret, nil
+}
+
+// Len returns the number of objects.
+func (m *MemoryIndex) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.objects) - 1 - len(m.removed)
+}
+
+// Close discards every object.
+func (m *MemoryIndex) Close() {
+	m.mu.Lock()
+	m.objects = make([][]float32, 1)
+	m.indexed = make([]bool, 1)
+	m.removed = nil
+	m.mu.Unlock()
+}
+
+// GetErrors returns errors
+func (m *MemoryIndex) GetErrors() []error {
+	m.emu.Lock()
+	defer m.emu.Unlock()
+	return m.errs
+}
+
+// validate returns error if vec does not fit the index.
+func (m *MemoryIndex) validate(vec []float64) error {
+	if len(vec) != m.prop.Dimension {
+		return m.addError(fmt.Errorf("%w: %d, wanted: %d", ErrDimension, len(vec), m.prop.Dimension))
+	}
+	return nil
+}
+
+// exists reports whether object id exists, caller must hold lock.
+func (m *MemoryIndex) exists(id int) bool {
+	return id > 0 && id < len(m.objects) && m.objects[id] != nil
+}
+
+// addError records err and returns it.
+func (m *MemoryIndex) addError(err error) error {
+	m.emu.Lock()
+	m.errs = append(m.errs, err)
+	m.emu.Unlock()
+	return err
+}
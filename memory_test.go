@@ -0,0 +1,178 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package gongt
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMemoryIndex(t *testing.T) {
+	m := NewMemoryIndex().SetDimension(3)
+	defer m.Close()
+	vectors := [][]float64{
+		{0, 0, 0},
+		{1, 0, 0},
+		{0, 2, 0},
+		{0, 0, 3},
+	}
+	ids, errs := m.BulkInsert(vectors)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected error: TestMemoryIndex(%v)", errs)
+	}
+	if want := []int{1, 2, 3, 4}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("TestMemoryIndex(ids): %v, wanted: %v", ids, want)
+	}
+
+	// objects are searched after CreateIndex
+	if result, err := m.Search(vectors[0], 4, DefaultEpsilon); err != nil || len(result) != 0 {
+		t.Errorf("TestMemoryIndex(before CreateIndex): %v %v, wanted: []", result, err)
+	}
+	if err := m.CreateIndex(DefaultPoolSize); err != nil {
+		t.Errorf("Unexpected error: TestMemoryIndex(%v)", err)
+	}
+	result, err := m.Search(vectors[0], 3, DefaultEpsilon)
+	if err != nil {
+		t.Errorf("Unexpected error: TestMemoryIndex(%v)", err)
+	}
+	if want := []SearchResult{{1, 0}, {2, 1}, {3, 2}}; !reflect.DeepEqual(result, want) {
+		t.Errorf("TestMemoryIndex(search): %v, wanted: %v", result, want)
+	}
+
+	if err := m.Remove(ids[1]); err != nil {
+		t.Errorf("Unexpected error: TestMemoryIndex(%v)", err)
+	}
+	if err := m.Remove(ids[1]); !errors.Is(err, ErrObjectID) {
+		t.Errorf("TestMemoryIndex(remove twice): %v, wanted: %v", err, ErrObjectID)
+	}
+	if _, err := m.GetVector(ids[1]); !errors.Is(err, ErrObjectID) {
+		t.Errorf("TestMemoryIndex(removed): %v, wanted: %v", err, ErrObjectID)
+	}
+	if got := m.Len(); got != len(vectors)-1 {
+		t.Errorf("TestMemoryIndex(len): %v, wanted: %v", got, len(vectors)-1)
+	}
+
+	// removed id is reused
+	id, err := m.Insert([]float64{5, 0, 0})
+	if err != nil || id != ids[1] {
+		t.Errorf("TestMemoryIndex(reuse): %v %v, wanted: %v", id, err, ids[1])
+	}
+	if got, _ := m.GetVector(id); !reflect.DeepEqual(got, []float64{5, 0, 0}) {
+		t.Errorf("TestMemoryIndex(%v): %v, wanted: %v", id, got, []float64{5, 0, 0})
+	}
+
+	if _, err := m.Insert([]float64{1, 2}); !errors.Is(err, ErrDimension) {
+		t.Errorf("TestMemoryIndex(dimension): %v, wanted: %v", err, ErrDimension)
+	}
+	if _, err := m.Search([]float64{1, 2}, 1, DefaultEpsilon); !errors.Is(err, ErrDimension) {
+		t.Errorf("TestMemoryIndex(dimension): %v, wanted: %v", err, ErrDimension)
+	}
+	for _, size := range []int{0, -1} {
+		if result, err := m.Search(vectors[0], size, DefaultEpsilon); err != nil || len(result) != 0 {
+			t.Errorf("TestMemoryIndex(size %v): %v %v, wanted: []", size, result, err)
+		}
+	}
+	if errs := m.GetErrors(); len(errs) != 4 {
+		t.Errorf("TestMemoryIndex(errors): %v, wanted: 4 errors", errs)
+	}
+}
+
+func TestMemoryIndexObjects(t *testing.T) {
+	tests := []struct {
+		ot   ObjectType
+		dt   DistanceType
+		vec  []float64
+		want []float64
+	}{
+		{Float, L2, []float64{1.5, 2.25}, []float64{1.5, 2.25}},
+		{Uint8, L2, []float64{1.5, 2.75}, []float64{1, 2}},
+	}
+
+	for _, tt := range tests {
+		m := NewMemoryIndex().SetDimension(2).SetObjectType(tt.ot).SetDistanceType(tt.dt)
+		id, err := m.Insert(tt.vec)
+		if err != nil {
+			t.Errorf("Unexpected error: TestMemoryIndexObjects(%v)", err)
+			continue
+		}
+		got, err := m.GetVector(id)
+		if err != nil {
+			t.Errorf("Unexpected error: TestMemoryIndexObjects(%v)", err)
+			continue
+		}
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-6 {
+				t.Errorf("TestMemoryIndexObjects(%v, %v, %v): %v, wanted: %v", tt.ot, tt.dt, tt.vec, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestMemoryIndexDistance(t *testing.T) {
+	tests := []struct {
+		ot    ObjectType
+		dt    DistanceType
+		vec   []float64
+		query []float64
+		want  float64
+	}{
+		{Float, L1, []float64{1, 2, 3}, []float64{3, 2, 0}, 5},
+		{Float, L2, []float64{0, 3, 0}, []float64{4, 0, 0}, 5},
+		{Float, Angle, []float64{1, 0, 0}, []float64{0, 2, 0}, math.Pi / 2},
+		{Float, Cosine, []float64{1, 0, 0}, []float64{-1, 0, 0}, 2},
+		{Uint8, Hamming, []float64{0xff, 1, 0}, []float64{0x0f, 2, 0}, 6},
+		{Uint8, L1, []float64{1.9, 0, 0}, []float64{0, 0, 0}, 1},
+	}
+
+	for _, tt := range tests {
+		m := NewMemoryIndex().SetDimension(3).SetObjectType(tt.ot).SetDistanceType(tt.dt)
+		if _, err := m.Insert(tt.vec); err != nil {
+			t.Errorf("Unexpected error: TestMemoryIndexDistance(%v)", err)
+			continue
+		}
+		if err := m.CreateIndex(DefaultPoolSize); err != nil {
+			t.Errorf("Unexpected error: TestMemoryIndexDistance(%v)", err)
+			continue
+		}
+		result, err := m.Search(tt.query, 1, DefaultEpsilon)
+		if err != nil || len(result) != 1 {
+			t.Errorf("Unexpected error: TestMemoryIndexDistance(%v %v)", result, err)
+			continue
+		}
+		if math.Abs(result[0].Distance-tt.want) > 1e-6 {
+			t.Errorf("TestMemoryIndexDistance(%v, %v, %v): %v, wanted: %v", tt.dt, tt.vec, tt.query, result[0].Distance, tt.want)
+		}
+	}
+}
+
+func TestMemoryIndexNormalized(t *testing.T) {
+	for _, dt := range []DistanceType{NormalizedAngle, NormalizedCosine} {
+		m := NewMemoryIndex().SetDimension(2).SetDistanceType(dt)
+		if errs := m.GetErrors(); len(errs) != 1 || !errors.Is(errs[0], ErrCAPINotImplemented) {
+			t.Errorf("TestMemoryIndexNormalized(%v): %v, wanted: %v", dt, errs, ErrCAPINotImplemented)
+		}
+		if _, err := m.Insert([]float64{3, 4}); !errors.Is(err, ErrCAPINotImplemented) {
+			t.Errorf("TestMemoryIndexNormalized(%v): %v, wanted: %v", dt, err, ErrCAPINotImplemented)
+		}
+		if got := m.Len(); got != 0 {
+			t.Errorf("TestMemoryIndexNormalized(%v): %v objects, wanted: 0", dt, got)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Operation is the kind of NGT call recorded by Metrics
+	Operation string
+
+	// Metrics records operations of an NGT index
+	Metrics interface {
+		// ObserveOperation records op which took elapsed and failed with err if not nil
+		ObserveOperation(op Operation, elapsed time.Duration, err error)
+		// ObserveResultSize records the number of results of a search
+		ObserveResultSize(size int)
+		// SetObjects records the number of live objects
+		SetObjects(count int)
+	}
+
+	// PrometheusMetrics keeps Metrics of indexes and writes them in Prometheus text format
+	PrometheusMetrics struct {
+		mu      *sync.Mutex
+		indexes map[string]*indexMetrics
+	}
+
+	// indexMetrics is Metrics of an index in PrometheusMetrics
+	indexMetrics struct {
+		mu      *sync.Mutex
+		ops     map[Operation]*opMetrics
+		results *histogram
+		objects int
+	}
+	opMetrics struct {
+		count    uint64
+		errors   uint64
+		duration *histogram
+	}
+	histogram struct {
+		bounds []float64
+		counts []uint64
+		sum    float64
+		count  uint64
+	}
+)
+
+const (
+	// OpOpen is Open, OpenInMemory and OpenReadOnly
+	OpOpen Operation = "open"
+	// OpSearch is Search and StrictSearch
+	OpSearch Operation = "search"
+	// OpInsert is Insert and StrictInsert
+	OpInsert Operation = "insert"
+	// OpRemove is Remove and StrictRemove
+	OpRemove Operation = "remove"
+	// OpCreateIndex is CreateIndex
+	OpCreateIndex Operation = "create_index"
+	// OpSaveIndex is SaveIndex and SaveTo
+	OpSaveIndex Operation = "save_index"
+)
+
+var (
+	// DurationBuckets are upper bounds in seconds of operation latency histograms
+	DurationBuckets = []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// ResultSizeBuckets are upper bounds of search result size histograms
+	ResultSizeBuckets = []float64{0, 1, 5, 10, 20, 50, 100, 200, 500, 1000}
+)
+
+// SetMetrics sets Metrics of the default NGT index
+func SetMetrics(m Metrics) *NGT {
+	return Get().SetMetrics(m)
+}
+
+// SetMetrics sets Metrics recording operations of NGT index, nil to disable.
+// Set it before Open to record the object count, which Open takes by reading every object.
+//	p := gongt.NewPrometheusMetrics()
+//	ngt := gongt.New("index Path").SetMetrics(p.Index("items")).Open()
+//	http.Handle("/metrics", p)
+func (n *NGT) SetMetrics(m Metrics) *NGT {
+	n.mu.Lock()
+	n.metrics = m
+	n.mu.Unlock()
+
+	return n
+}
+
+// observe records operation op started at start.
+func (n *NGT) observe(op Operation, start time.Time, err error) {
+	if n.metrics != nil {
+		n.metrics.ObserveOperation(op, time.Since(start), err)
+	}
+}
+
+// observeErrors records operation op started at start, which failed if errors are added after before.
+func (n *NGT) observeErrors(op Operation, start time.Time, before int) {
+	var err error
+	if len(n.errs) > before {
+		err = n.errs[before]
+	}
+	n.observe(op, start, err)
+}
+
+// countObjects takes the number of live objects for Metrics, caller must hold write lock.
+func (n *NGT) countObjects() {
+	if n.metrics == nil {
+		return
+	}
+	n.objects = 0
+	n.rangeObjects(func(uint, []float32) bool {
+		n.objects++
+		return true
+	})
+	n.metrics.SetObjects(n.objects)
+}
+
+// addObjects adds delta to the number of live objects, caller must hold write lock.
+func (n *NGT) addObjects(delta int) {
+	if n.metrics != nil {
+		n.objects += delta
+		n.metrics.SetObjects(n.objects)
+	}
+}
+
+// NewPrometheusMetrics returns empty PrometheusMetrics
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		mu:      &sync.Mutex{},
+		indexes: make(map[string]*indexMetrics),
+	}
+}
+
+// Index returns Metrics labeled with index name, the same one for the same name.
+func (p *PrometheusMetrics) Index(name string) Metrics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m, ok := p.indexes[name]
+	if !ok {
+		m = &indexMetrics{
+			mu:      &sync.Mutex{},
+			ops:     make(map[Operation]*opMetrics),
+			results: newHistogram(ResultSizeBuckets),
+		}
+		p.indexes[name] = m
+	}
+	return m
+}
+
+func (m *indexMetrics) ObserveOperation(op Operation, elapsed time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, ok := m.ops[op]
+	if !ok {
+		o = &opMetrics{duration: newHistogram(DurationBuckets)}
+		m.ops[op] = o
+	}
+	o.count++
+	if err != nil {
+		o.errors++
+	}
+	o.duration.observe(elapsed.Seconds())
+}
+
+func (m *indexMetrics) ObserveResultSize(size int) {
+	m.mu.Lock()
+	m.results.observe(float64(size))
+	m.mu.Unlock()
+}
+
+func (m *indexMetrics) SetObjects(count int) {
+	m.mu.Lock()
+	m.objects = count
+	m.mu.Unlock()
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.bounds {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// write writes h as series name with labels in Prometheus text format.
+func (h *histogram) write(w *bufio.Writer, name, labels string) {
+	for i, b := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(b), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// WriteTo writes every metric to w in Prometheus text format.
+func (p *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	names := make([]string, 0, len(p.indexes))
+	for name := range p.indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	indexes := make([]*indexMetrics, len(names))
+	for i, name := range names {
+		indexes[i] = p.indexes[name]
+	}
+	p.mu.Unlock()
+
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	families := []struct {
+		name, help, typ string
+		write           func(labels string, m *indexMetrics)
+	}{
+		{"gongt_operations_total", "Operations by index and operation.", "counter", func(labels string, m *indexMetrics) {
+			for _, op := range m.sortedOps() {
+				fmt.Fprintf(bw, "gongt_operations_total{%s,op=\"%s\"} %d\n", labels, op, m.ops[op].count)
+			}
+		}},
+		{"gongt_operation_errors_total", "Failed operations by index and operation.", "counter", func(labels string, m *indexMetrics) {
+			for _, op := range m.sortedOps() {
+				fmt.Fprintf(bw, "gongt_operation_errors_total{%s,op=\"%s\"} %d\n", labels, op, m.ops[op].errors)
+			}
+		}},
+		{"gongt_operation_duration_seconds", "Latency of operations by index and operation.", "histogram", func(labels string, m *indexMetrics) {
+			for _, op := range m.sortedOps() {
+				m.ops[op].duration.write(bw, "gongt_operation_duration_seconds", labels+",op=\""+string(op)+"\"")
+			}
+		}},
+		{"gongt_search_result_size", "Number of results of searches.", "histogram", func(labels string, m *indexMetrics) {
+			m.results.write(bw, "gongt_search_result_size", labels)
+		}},
+		{"gongt_objects", "Number of live objects.", "gauge", func(labels string, m *indexMetrics) {
+			fmt.Fprintf(bw, "gongt_objects{%s} %d\n", labels, m.objects)
+		}},
+	}
+	for _, f := range families {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ)
+		for i, name := range names {
+			m := indexes[i]
+			m.mu.Lock()
+			f.write("index=\""+escapeLabel(name)+"\"", m)
+			m.mu.Unlock()
+		}
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP writes every metric in Prometheus text format.
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	p.WriteTo(w)
+}
+
+func (m *indexMetrics) sortedOps() []Operation {
+	ops := make([]Operation, 0, len(m.ops))
+	for op := range m.ops {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i] < ops[j] })
+	return ops
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,132 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	p := NewPrometheusMetrics()
+	m := p.Index(`a"b`)
+	if p.Index(`a"b`) != m {
+		t.Errorf("TestPrometheusMetrics: Index returns another Metrics for the same name")
+	}
+	m.ObserveOperation(OpSearch, 2*time.Millisecond, nil)
+	m.ObserveOperation(OpSearch, 20*time.Millisecond, errors.New("failed"))
+	m.ObserveResultSize(10)
+	m.SetObjects(42)
+
+	buf := new(bytes.Buffer)
+	size, err := p.WriteTo(buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: TestPrometheusMetrics(%v)", err)
+	}
+	if size != int64(buf.Len()) {
+		t.Errorf("TestPrometheusMetrics(size): %v, wanted: %v", size, buf.Len())
+	}
+	tests := []string{
+		"# TYPE gongt_operations_total counter",
+		`gongt_operations_total{index="a\"b",op="search"} 2`,
+		`gongt_operation_errors_total{index="a\"b",op="search"} 1`,
+		`gongt_operation_duration_seconds_bucket{index="a\"b",op="search",le="0.0025"} 1`,
+		`gongt_operation_duration_seconds_bucket{index="a\"b",op="search",le="+Inf"} 2`,
+		`gongt_operation_duration_seconds_sum{index="a\"b",op="search"} 0.022`,
+		`gongt_search_result_size_bucket{index="a\"b",le="5"} 0`,
+		`gongt_search_result_size_bucket{index="a\"b",le="10"} 1`,
+		`gongt_search_result_size_count{index="a\"b"} 1`,
+		`gongt_objects{index="a\"b"} 42`,
+	}
+	for _, tt := range tests {
+		if !strings.Contains(buf.String(), tt+"\n") {
+			t.Errorf("TestPrometheusMetrics(%v): not found in\n%s", tt, buf)
+		}
+	}
+}
+
+type recordingMetrics struct {
+	ops     map[Operation]int
+	errs    map[Operation]int
+	results []int
+	objects int
+}
+
+func (m *recordingMetrics) ObserveOperation(op Operation, elapsed time.Duration, err error) {
+	m.ops[op]++
+	if err != nil {
+		m.errs[op]++
+	}
+}
+
+func (m *recordingMetrics) ObserveResultSize(size int) {
+	m.results = append(m.results, size)
+}
+
+func (m *recordingMetrics) SetObjects(count int) {
+	m.objects = count
+}
+
+func TestMetrics(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestMetrics(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	m := &recordingMetrics{ops: make(map[Operation]int), errs: make(map[Operation]int)}
+	n := New(filepath.Join(tmpdir, "index")).SetObjectType(Uint8).SetDimension(6).SetMetrics(m).Open()
+	defer n.Close()
+	ids, errs := n.BulkInsert([][]float64{{1, 0, 0, 0, 0, 0}, {0, 1, 0, 0, 0, 0}})
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected error: TestMetrics(%v)", errs)
+	}
+	if err := n.CreateAndSaveIndex(poolSize); err != nil {
+		t.Errorf("Unexpected error: TestMetrics(%v)", err)
+	}
+	if _, err := n.Search([]float64{1, 0, 0, 0, 0, 0}, 2, DefaultEpsilon); err != nil {
+		t.Errorf("Unexpected error: TestMetrics(%v)", err)
+	}
+	if err := n.Remove(ids[0]); err != nil {
+		t.Errorf("Unexpected error: TestMetrics(%v)", err)
+	}
+	n.Remove(ids[0])
+
+	want := map[Operation]int{OpOpen: 1, OpInsert: 2, OpCreateIndex: 1, OpSaveIndex: 1, OpSearch: 1, OpRemove: 2}
+	for op, count := range want {
+		if m.ops[op] != count {
+			t.Errorf("TestMetrics(%v): %v, wanted: %v", op, m.ops[op], count)
+		}
+	}
+	if m.errs[OpRemove] != 1 {
+		t.Errorf("TestMetrics(remove errors): %v, wanted: %v", m.errs[OpRemove], 1)
+	}
+	if len(m.results) != 1 || m.results[0] != 2 {
+		t.Errorf("TestMetrics(results): %v, wanted: %v", m.results, []int{2})
+	}
+	if m.objects != 1 {
+		t.Errorf("TestMetrics(objects): %v, wanted: %v", m.objects, 1)
+	}
+}
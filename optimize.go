@@ -0,0 +1,122 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/yahoojapan/gongt/internal/ngtfile"
+)
+
+// OptimizeConfig is parameters for Optimize
+type OptimizeConfig struct {
+	// OutgoingEdges is the number of nearest outgoing edges each node keeps,
+	// DefaultOutgoingEdges if 0 and every edge if negative
+	OutgoingEdges int
+	// IncomingEdges is the number of nearest edges of each node added to the other end
+	// as reverse edges, DefaultIncomingEdges if 0 and none if negative
+	IncomingEdges int
+	// PathPruning removes edges to nodes reachable through a nearer neighbor by a shorter edge
+	PathPruning bool
+}
+
+const (
+	// DefaultOutgoingEdges is 10
+	DefaultOutgoingEdges = 10
+	// DefaultIncomingEdges is 100
+	DefaultIncomingEdges = 100
+)
+
+// Optimize writes default NGT index with reconstructed graph into dst.
+func Optimize(dst string, cfg OptimizeConfig) error {
+	return Get().Optimize(dst, cfg)
+}
+
+// Optimize writes NGT index with reconstructed graph into dst, which must be empty or not exist.
+// The graph is adjusted in the way NGT makes ONNG and PANNG from ANNG; objects and
+// tree are copied as they are. The source index is not modified.
+func (n *NGT) Optimize(dst string, cfg OptimizeConfig) error {
+	if err := n.optimize(dst, cfg); err != nil {
+		n.errs = append(n.errs, err)
+		return err
+	}
+	return nil
+}
+
+func (n *NGT) optimize(dst string, cfg OptimizeConfig) error {
+	if cfg.OutgoingEdges == 0 {
+		cfg.OutgoingEdges = DefaultOutgoingEdges
+	}
+	if cfg.IncomingEdges == 0 {
+		cfg.IncomingEdges = DefaultIncomingEdges
+	}
+	if infos, err := ioutil.ReadDir(dst); err == nil && len(infos) > 0 {
+		return fmt.Errorf("optimize: %s is not empty", dst)
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	n.mu.RLock()
+	err := n.saveTo(dst)
+	n.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dst, ngtfile.GraphFile)
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	g, err := ngtfile.ReadGraph(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	g = g.Reconstruct(cfg.OutgoingEdges, cfg.IncomingEdges)
+	if cfg.PathPruning {
+		g = g.PrunePaths()
+	}
+
+	f, err = os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err = g.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	// objects are not changed by optimization
+	m, err := ngtfile.ReadManifest(dst)
+	if err != nil {
+		return err
+	}
+	if err = ngtfile.WriteManifest(dst, Version, NGTVersion, m.Objects); err != nil {
+		return err
+	}
+	return syncDir(dst)
+}
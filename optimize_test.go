@@ -0,0 +1,79 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/yahoojapan/gongt/internal/ngtfile"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		cfg OptimizeConfig
+	}{
+		{OptimizeConfig{}},
+		{OptimizeConfig{OutgoingEdges: 2, IncomingEdges: 2}},
+		{OptimizeConfig{OutgoingEdges: -1, IncomingEdges: -1, PathPruning: true}},
+	}
+	queries := [][]float64{
+		{1, 0, 0, 0, 0, 0},
+		{0, 1, 0, 0, 0, 0},
+		{0, 0, 1, 0, 0, 0},
+		{0, 0, 0, 1, 0, 0},
+		{0, 0, 0, 0, 1, 0},
+		{1, 1, 0, 0, 0, 0},
+	}
+	neighbors := [][]int{{1}, {2}, {3}, {4}, {5}, {6}}
+
+	ngt := New(index).Open()
+	defer ngt.Close()
+	for _, tt := range tests {
+		tmpdir, err := ioutil.TempDir("", "tmpdir")
+		if err != nil {
+			t.Errorf("Unexpected error: TestOptimize(%v)", err)
+		}
+		defer os.RemoveAll(tmpdir)
+
+		dst := path.Join(tmpdir, "optimized")
+		if err := ngt.Optimize(dst, tt.cfg); err != nil {
+			t.Errorf("Unexpected error: TestOptimize(%v)", err)
+			continue
+		}
+		if errs := ngtfile.Verify(dst); len(errs) > 0 {
+			t.Errorf("Unexpected error: TestOptimize(%v)", errs)
+		}
+
+		o := New(dst).Open()
+		if errs := o.GetErrors(); len(errs) > 0 {
+			t.Errorf("Unexpected error: TestOptimize(%v)", errs)
+		}
+		e, err := o.Evaluate(queries, neighbors, 1, DefaultEpsilon)
+		if err != nil {
+			t.Errorf("Unexpected error: TestOptimize(%v)", err)
+		}
+		if e.Recall != 1 {
+			t.Errorf("TestOptimize(%+v): recall %v, wanted: %v", tt.cfg, e.Recall, 1)
+		}
+		o.Close()
+	}
+}
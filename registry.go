@@ -0,0 +1,208 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+type (
+	// Registry opens, shares and closes named NGT indexes by reference count
+	Registry struct {
+		mu      *sync.Mutex
+		entries map[string]*registryEntry
+	}
+	registryEntry struct {
+		n    *NGT
+		refs int
+		// opening is closed when Open of n called outside the lock returns
+		opening chan struct{}
+	}
+)
+
+// DefaultName is the name of the index package-level functions operate on
+const DefaultName = "default"
+
+var (
+	// DefaultRegistry holds the index package-level functions operate on
+	DefaultRegistry = NewRegistry()
+
+	// ErrAlreadyRegistered raises registering a name twice
+	ErrAlreadyRegistered = errors.New("index is already registered")
+	// ErrNotAcquired raises releasing an index which is not acquired
+	ErrNotAcquired = errors.New("index is not acquired")
+	// ErrNotRegistered raises acquiring a name which is not registered
+	ErrNotRegistered = errors.New("index is not registered")
+)
+
+// NewRegistry returns empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		mu:      &sync.Mutex{},
+		entries: make(map[string]*registryEntry),
+	}
+}
+
+// Register adds configured NGT instance under name without opening it.
+// It is opened by the first Acquire.
+//	r.Register("items", gongt.New("/var/lib/items").SetDimension(128))
+func (r *Registry) Register(name string, n *NGT) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entries[name]; ok {
+		return ErrAlreadyRegistered
+	}
+	r.entries[name] = &registryEntry{n: n}
+	return nil
+}
+
+// Acquire returns NGT index named name and increments its reference count.
+// The index is opened if it is not open yet, without blocking other names meanwhile.
+// name must be registered by Register, or use AcquirePath to open an index by its path.
+//	n, err := r.Acquire("items")
+//	defer r.Release("items")
+func (r *Registry) Acquire(name string) (*NGT, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[name]
+	if !ok {
+		return nil, ErrNotRegistered
+	}
+	r.wait(e)
+	if e.refs == 0 && !e.n.isOpen() {
+		e.opening = make(chan struct{})
+		r.mu.Unlock()
+		before := len(e.n.GetErrors())
+		errs := e.n.Open().GetErrors()
+		if len(errs) > before {
+			e.n.Close()
+		}
+		r.mu.Lock()
+		close(e.opening)
+		e.opening = nil
+		if len(errs) > before {
+			return nil, errs[before]
+		}
+	}
+	e.refs++
+	return e.n, nil
+}
+
+// AcquirePath returns NGT index at path like Acquire, registering New(path) under
+// the cleaned path first if missing. Release it by ReleasePath.
+//	n, err := r.AcquirePath("/var/lib/items")
+//	defer r.ReleasePath("/var/lib/items")
+func (r *Registry) AcquirePath(path string) (*NGT, error) {
+	path = filepath.Clean(path)
+	r.lookupOrRegister(path, func() *NGT {
+		return New(path)
+	})
+	return r.Acquire(path)
+}
+
+// ReleasePath releases NGT index acquired by AcquirePath(path).
+func (r *Registry) ReleasePath(path string) error {
+	return r.Release(filepath.Clean(path))
+}
+
+// wait waits until nobody is opening e, caller must hold r.mu.
+func (r *Registry) wait(e *registryEntry) {
+	for e.opening != nil {
+		opening := e.opening
+		r.mu.Unlock()
+		<-opening
+		r.mu.Lock()
+	}
+}
+
+// Release decrements reference count of index named name and closes it
+// when nothing refers to it. The configuration is kept for the next Acquire.
+func (r *Registry) Release(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[name]
+	if !ok || e.refs == 0 {
+		return ErrNotAcquired
+	}
+	e.refs--
+	if e.refs == 0 {
+		e.n.Close()
+	}
+	return nil
+}
+
+// Lookup returns NGT instance named name without opening it or changing its reference count.
+func (r *Registry) Lookup(name string) (*NGT, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[name]
+	if !ok {
+		return nil, false
+	}
+	return e.n, true
+}
+
+// Names returns registered names in sorted order.
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.entries))
+	for name := range r.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CloseAll closes every index regardless of reference count, waiting for ones being opened.
+func (r *Registry) CloseAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := make([]*registryEntry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, e)
+	}
+	for _, e := range entries {
+		r.wait(e)
+		e.n.Close()
+		e.refs = 0
+	}
+}
+
+// lookupOrRegister returns NGT instance named name, registering the one made by fn if missing.
+func (r *Registry) lookupOrRegister(name string, fn func() *NGT) *NGT {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[name]
+	if !ok {
+		e = &registryEntry{n: fn()}
+		r.entries[name] = e
+	}
+	return e.n
+}
+
+// isOpen reports whether NGT index is open.
+func (n *NGT) isOpen() bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.index != nil
+}
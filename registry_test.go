@@ -0,0 +1,218 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	if _, err := r.Acquire(index); err != ErrNotRegistered {
+		t.Errorf("TestRegistry: %v, wanted: %v", err, ErrNotRegistered)
+	}
+	if err := r.Register(index, New(index)); err != nil {
+		t.Errorf("Unexpected error: TestRegistry(%v)", err)
+	}
+
+	a, err := r.Acquire(index)
+	if err != nil {
+		t.Errorf("Unexpected error: TestRegistry(%v)", err)
+	}
+	b, err := r.Acquire(index)
+	if err != nil {
+		t.Errorf("Unexpected error: TestRegistry(%v)", err)
+	}
+	if a != b {
+		t.Errorf("TestRegistry: Acquire returns different instances for the same name")
+	}
+	if result, err := a.Search([]float64{1, 0, 0, 0, 0, 0}, 1, DefaultEpsilon); err != nil || len(result) == 0 || result[0].ID != 1 {
+		t.Errorf("TestRegistry: %v, %v, wanted: %v", result, err, 1)
+	}
+
+	tests := []struct {
+		open bool
+		want error
+	}{
+		{true, nil},
+		{false, nil},
+		{false, ErrNotAcquired},
+	}
+	for i, tt := range tests {
+		if err := r.Release(index); err != tt.want {
+			t.Errorf("TestRegistry(%v): %v, wanted: %v", i, err, tt.want)
+		}
+		if open := a.isOpen(); open != tt.open {
+			t.Errorf("TestRegistry(%v): open %v, wanted: %v", i, open, tt.open)
+		}
+	}
+
+	// released index is opened again
+	c, err := r.Acquire(index)
+	if err != nil {
+		t.Errorf("Unexpected error: TestRegistry(%v)", err)
+	}
+	if c != a || !c.isOpen() {
+		t.Errorf("TestRegistry: released index is not reopened")
+	}
+	r.CloseAll()
+	if c.isOpen() {
+		t.Errorf("TestRegistry: CloseAll leaves index open")
+	}
+}
+
+func TestRegistryRegister(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestRegistryRegister(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	r := NewRegistry()
+	defer r.CloseAll()
+
+	n := New(tmpdir).SetObjectType(Uint8).SetDimension(6)
+	if err := r.Register("items", n); err != nil {
+		t.Errorf("Unexpected error: TestRegistryRegister(%v)", err)
+	}
+	if err := r.Register("items", New(tmpdir)); err != ErrAlreadyRegistered {
+		t.Errorf("TestRegistryRegister: %v, wanted: %v", err, ErrAlreadyRegistered)
+	}
+	if n.isOpen() {
+		t.Errorf("TestRegistryRegister: registered index is opened before Acquire")
+	}
+	if got, ok := r.Lookup("items"); !ok || got != n {
+		t.Errorf("TestRegistryRegister: Lookup returns %v, %v", got, ok)
+	}
+
+	got, err := r.Acquire("items")
+	if err != nil {
+		t.Errorf("Unexpected error: TestRegistryRegister(%v)", err)
+	}
+	if got != n || !n.isOpen() {
+		t.Errorf("TestRegistryRegister: registered index is not opened by Acquire")
+	}
+	if got.GetDim() != 6 {
+		t.Errorf("TestRegistryRegister: dimension %v, wanted: %v", got.GetDim(), 6)
+	}
+	if names := r.Names(); !reflect.DeepEqual(names, []string{"items"}) {
+		t.Errorf("TestRegistryRegister: %v, wanted: %v", names, []string{"items"})
+	}
+}
+
+func TestRegistryAcquirePath(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestRegistryAcquirePath(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	n := New(tmpdir).SetObjectType(Uint8).SetDimension(6).Open()
+	if _, err := n.Insert([]float64{1, 2, 3, 4, 5, 6}); err != nil {
+		t.Errorf("Unexpected error: TestRegistryAcquirePath(%v)", err)
+	}
+	if err := n.CreateAndSaveIndex(1); err != nil {
+		t.Errorf("Unexpected error: TestRegistryAcquirePath(%v)", err)
+	}
+	n.Close()
+
+	r := NewRegistry()
+	defer r.CloseAll()
+
+	a, err := r.AcquirePath(tmpdir)
+	if err != nil {
+		t.Errorf("Unexpected error: TestRegistryAcquirePath(%v)", err)
+	}
+	b, err := r.AcquirePath(tmpdir + "/")
+	if err != nil {
+		t.Errorf("Unexpected error: TestRegistryAcquirePath(%v)", err)
+	}
+	if a != b {
+		t.Errorf("TestRegistryAcquirePath: same path returns different indexes")
+	}
+	if a.GetDim() != 6 || a.Len() != 1 {
+		t.Errorf("TestRegistryAcquirePath: dimension %v, len %v, wanted: %v, %v", a.GetDim(), a.Len(), 6, 1)
+	}
+	if names := r.Names(); !reflect.DeepEqual(names, []string{tmpdir}) {
+		t.Errorf("TestRegistryAcquirePath: %v, wanted: %v", names, []string{tmpdir})
+	}
+	for i := 0; i < 2; i++ {
+		if err := r.ReleasePath(tmpdir); err != nil {
+			t.Errorf("Unexpected error: TestRegistryAcquirePath(%v)", err)
+		}
+	}
+	if a.isOpen() {
+		t.Errorf("TestRegistryAcquirePath: released index is still open")
+	}
+}
+
+func TestRegistryConcurrentAcquire(t *testing.T) {
+	r := NewRegistry()
+	defer r.CloseAll()
+	if err := r.Register("index", New(index)); err != nil {
+		t.Errorf("Unexpected error: TestRegistryConcurrentAcquire(%v)", err)
+	}
+
+	const acquirers = 8
+	got := make([]*NGT, acquirers)
+	wg := &sync.WaitGroup{}
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n, err := r.Acquire("index")
+			if err != nil {
+				t.Errorf("Unexpected error: TestRegistryConcurrentAcquire(%v)", err)
+			}
+			got[i] = n
+		}(i)
+	}
+	wg.Wait()
+
+	for _, n := range got[1:] {
+		if n != got[0] {
+			t.Errorf("TestRegistryConcurrentAcquire: Acquire returns different instances")
+		}
+	}
+	for i := 0; i < acquirers; i++ {
+		if err := r.Release("index"); err != nil {
+			t.Errorf("TestRegistryConcurrentAcquire(%v): %v, wanted: nil", i, err)
+		}
+	}
+	if got[0].isOpen() {
+		t.Errorf("TestRegistryConcurrentAcquire: index is open after every Release")
+	}
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	n, ok := DefaultRegistry.Lookup(DefaultName)
+	if ok && n != Get() {
+		t.Errorf("TestDefaultRegistry: Get returns instance other than the registered one")
+	}
+	if Get() != Get() {
+		t.Errorf("TestDefaultRegistry: Get returns different instances")
+	}
+	if n, ok := DefaultRegistry.Lookup(DefaultName); !ok || n != Get() {
+		t.Errorf("TestDefaultRegistry: default instance is not registered")
+	}
+}
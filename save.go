@@ -0,0 +1,230 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+/*
+#include <stdlib.h>
+#include <NGT/Capi.h>
+*/
+import "C"
+
+import (
+	"context"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+	"unsafe"
+
+	"github.com/yahoojapan/gongt/internal/ngtfile"
+)
+
+const (
+	// PreviousIndexSuffix is appended to IndexPath for the previous generation
+	PreviousIndexSuffix = ".prev"
+
+	tmpIndexSuffix = ".tmp-"
+)
+
+// SaveTo writes default NGT index into path
+func SaveTo(path string) error {
+	return Get().SaveTo(path)
+}
+
+// SaveTo writes NGT index into path in the same way as SaveIndex.
+// An in-memory index takes path as its IndexPath, so later SaveIndex writes there.
+func (n *NGT) SaveTo(path string) error {
+	if err := n.flushStaging(); err != nil {
+		return err
+	}
+
+	n.smu.Lock()
+	defer n.smu.Unlock()
+
+	if n.readOnly && filepath.Clean(path) == filepath.Clean(n.prop.IndexPath) {
+		n.errs = append(n.errs, ErrReadOnly)
+		return ErrReadOnly
+	}
+	start := time.Now()
+	err := os.MkdirAll(filepath.Dir(filepath.Clean(path)), 0755)
+	if err == nil {
+		n.mu.RLock()
+		err = n.saveIndexTo(path)
+		n.mu.RUnlock()
+	}
+	n.observe(OpSaveIndex, start, err)
+	if err != nil {
+		n.errs = append(n.errs, err)
+		return err
+	}
+
+	n.mu.Lock()
+	if n.prop.IndexPath == "" {
+		n.prop.IndexPath = path
+	}
+	n.mu.Unlock()
+	return nil
+}
+
+// saveIndex writes NGT index to IndexPath, caller must hold read lock.
+func (n *NGT) saveIndex() error {
+	if n.readOnly {
+		return ErrReadOnly
+	}
+	if n.prop.IndexPath == "" {
+		return ErrNoIndexPath
+	}
+	return n.saveIndexTo(n.prop.IndexPath)
+}
+
+// saveIndexTo writes NGT index into a sibling temporary directory and swaps it with path,
+// so path always holds either the old or the new index.
+// Caller must hold read lock.
+func (n *NGT) saveIndexTo(path string) error {
+	start := time.Now()
+	path = filepath.Clean(path)
+	tmp, err := ioutil.TempDir(filepath.Dir(path), filepath.Base(path)+tmpIndexSuffix)
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp, 0755); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err = n.saveTo(tmp); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err = swapIndex(tmp, path, n.prop.KeepPreviousIndex); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	n.log(slog.LevelInfo, "saved index", "to", path, "duration", time.Since(start), "bytes", indexBytes(path))
+	if n.wal != nil && path == filepath.Clean(n.prop.IndexPath) {
+		// the saved index includes every logged record
+		return n.wal.reset(filepath.Join(path, WALFile))
+	}
+	return nil
+}
+
+// saveTo writes NGT index and its manifest into dir and flushes them to storage.
+// Caller must hold read lock.
+func (n *NGT) saveTo(dir string) error {
+	ebuf := C.ngt_create_error_object()
+	defer C.ngt_destroy_error_object(ebuf)
+
+	cdir := C.CString(dir)
+	defer C.free(unsafe.Pointer(cdir))
+	span := n.startSpan(context.Background(), SpanSaveIndex, Attribute{"ngt.path", dir})
+	if C.ngt_save_index(n.index, cdir, ebuf) == ErrorCode {
+		err := n.cError(ebuf)
+		endSpan(span, err)
+		return err
+	}
+	endSpan(span, nil)
+	objects := 0
+	n.rangeObjects(func(uint, []float32) bool {
+		objects++
+		return true
+	})
+	if err := ngtfile.WriteManifest(dir, Version, NGTVersion, objects); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// swapIndex replaces path with tmp, moving the current index to path+PreviousIndexSuffix.
+func swapIndex(tmp, path string, keep bool) error {
+	prev := path + PreviousIndexSuffix
+	if err := os.RemoveAll(prev); err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		if err = os.Rename(path, prev); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		// put the previous generation back
+		os.Rename(prev, path)
+		return err
+	}
+	if err := syncFile(filepath.Dir(path)); err != nil {
+		return err
+	}
+	if !keep {
+		return os.RemoveAll(prev)
+	}
+	return nil
+}
+
+// recoverIndex cleans up after a SaveIndex interrupted by a crash.
+// If IndexPath has no property file, the previous generation is moved back.
+func recoverIndex(path string) error {
+	path = filepath.Clean(path)
+	tmps, err := filepath.Glob(path + tmpIndexSuffix + "*")
+	if err != nil {
+		return err
+	}
+	for _, tmp := range tmps {
+		if err = os.RemoveAll(tmp); err != nil {
+			return err
+		}
+	}
+
+	if _, err = os.Stat(filepath.Join(path, ngtfile.PropertyFile)); !os.IsNotExist(err) {
+		return nil
+	}
+	prev := path + PreviousIndexSuffix
+	if _, err = os.Stat(filepath.Join(prev, ngtfile.PropertyFile)); err != nil {
+		return nil
+	}
+	if err = os.RemoveAll(path); err != nil {
+		return err
+	}
+	return os.Rename(prev, path)
+}
+
+// syncDir flushes every file in dir and dir itself.
+func syncDir(dir string) error {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if info.Mode().IsRegular() {
+			if err = syncFile(filepath.Join(dir, info.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return syncFile(dir)
+}
+
+func syncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
@@ -0,0 +1,143 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/yahoojapan/gongt/internal/ngtfile"
+)
+
+func TestSaveIndex(t *testing.T) {
+	tests := []struct {
+		keep bool
+		want bool
+	}{
+		{false, false},
+		{true, true},
+	}
+
+	for _, tt := range tests {
+		tmpdir, err := ioutil.TempDir("", "tmpdir")
+		if err != nil {
+			t.Errorf("Unexpected error: TestSaveIndex(%v)", err)
+		}
+		defer os.RemoveAll(tmpdir)
+
+		indexPath := path.Join(tmpdir, "index")
+		ngt := New(indexPath).SetObjectType(Uint8).SetDimension(6).SetKeepPreviousIndex(tt.keep).Open()
+		if _, err := ngt.Insert([]float64{1, 0, 0, 0, 0, 0}); err != nil {
+			t.Errorf("Unexpected error: TestSaveIndex(%v)", err)
+		}
+		if err := ngt.CreateAndSaveIndex(poolSize); err != nil {
+			t.Errorf("Unexpected error: TestSaveIndex(%v)", err)
+		}
+		ngt.Close()
+
+		if _, err := os.Stat(path.Join(indexPath, ngtfile.PropertyFile)); err != nil {
+			t.Errorf("Unexpected error: TestSaveIndex(%v)", err)
+		}
+		_, err = os.Stat(path.Join(indexPath+PreviousIndexSuffix, ngtfile.PropertyFile))
+		if got := err == nil; got != tt.want {
+			t.Errorf("TestSaveIndex(%v): previous index exists %v, wanted: %v", tt.keep, got, tt.want)
+		}
+		if tmps, _ := filepath.Glob(indexPath + tmpIndexSuffix + "*"); len(tmps) > 0 {
+			t.Errorf("TestSaveIndex(%v): temporary directories are left %v", tt.keep, tmps)
+		}
+	}
+}
+
+func TestOpenRecoversPreviousIndex(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestOpenRecoversPreviousIndex(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	// crash between moving the current index away and moving the new one in
+	indexPath := path.Join(tmpdir, "index")
+	if err := exec.Command("cp", "-r", index, indexPath+PreviousIndexSuffix).Run(); err != nil {
+		t.Errorf("Unexpected error: TestOpenRecoversPreviousIndex(%v)", err)
+	}
+	if err := os.Mkdir(indexPath+tmpIndexSuffix+"0", 0755); err != nil {
+		t.Errorf("Unexpected error: TestOpenRecoversPreviousIndex(%v)", err)
+	}
+
+	ngt := New(indexPath).Open()
+	defer ngt.Close()
+	if errs := ngt.GetErrors(); len(errs) > 0 {
+		t.Errorf("Unexpected error: TestOpenRecoversPreviousIndex(%v)", errs)
+	}
+	result, err := ngt.Search([]float64{1, 0, 0, 0, 0, 0}, 1, DefaultEpsilon)
+	if err != nil {
+		t.Errorf("Unexpected error: TestOpenRecoversPreviousIndex(%v)", err)
+	}
+	if len(result) == 0 || result[0].ID != 1 {
+		t.Errorf("TestOpenRecoversPreviousIndex: %v, wanted: %v", result, 1)
+	}
+	if _, err := os.Stat(indexPath + tmpIndexSuffix + "0"); !os.IsNotExist(err) {
+		t.Errorf("TestOpenRecoversPreviousIndex: temporary directory is left")
+	}
+}
+
+func TestSaveTo(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestSaveTo(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	ngt := New("").SetObjectType(Uint8).SetDimension(6).OpenInMemory()
+	defer ngt.Close()
+	if _, err := ngt.Insert([]float64{1, 0, 0, 0, 0, 0}); err != nil {
+		t.Errorf("Unexpected error: TestSaveTo(%v)", err)
+	}
+	if err := ngt.CreateIndex(poolSize); err != nil {
+		t.Errorf("Unexpected error: TestSaveTo(%v)", err)
+	}
+
+	indexPath := path.Join(tmpdir, "index")
+	if err := ngt.SaveTo(indexPath); err != nil {
+		t.Errorf("Unexpected error: TestSaveTo(%v)", err)
+	}
+	if ngt.GetPath() != indexPath {
+		t.Errorf("TestSaveTo: index path %v, wanted: %v", ngt.GetPath(), indexPath)
+	}
+	if _, err := ngt.Insert([]float64{0, 1, 0, 0, 0, 0}); err != nil {
+		t.Errorf("Unexpected error: TestSaveTo(%v)", err)
+	}
+	if err := ngt.CreateAndSaveIndex(poolSize); err != nil {
+		t.Errorf("Unexpected error: TestSaveTo(%v)", err)
+	}
+
+	saved := New(indexPath).Open()
+	defer saved.Close()
+	if errs := saved.GetErrors(); len(errs) > 0 {
+		t.Errorf("Unexpected error: TestSaveTo(%v)", errs)
+	}
+	if got := saved.IDs(); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("TestSaveTo: %v, wanted: %v", got, []int{1, 2})
+	}
+}
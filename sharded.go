@@ -0,0 +1,363 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/yahoojapan/gongt/internal/ngtfile"
+)
+
+// Sharded spreads objects over several NGT indexes in subdirectories of one directory,
+// so that inserts into one shard do not block searches of the others and
+// CreateIndex and SaveIndex run on every shard in parallel.
+// Objects are assigned to shards round-robin and the id of an object is
+// local id * number of shards + shard number. Only round-robin placement is supported,
+// because NGT assigns the id on insert and there is nothing to hash before choosing a shard.
+type Sharded struct {
+	// next is accessed atomically and kept first for 64bit alignment
+	next   uint64
+	path   string
+	size   int
+	prop   Property
+	shards []*NGT
+	emu    *sync.Mutex
+	errs   []error
+}
+
+var (
+	// ErrNoShards raises opening new Sharded index without the number of shards
+	ErrNoShards = errors.New("the number of shards is not set")
+	// ErrShardID raises using id which no shard holds
+	ErrShardID = errors.New("id does not belong to any shard")
+)
+
+// Sharded implements Index
+var _ Index = (*Sharded)(nil)
+
+// NewSharded returns Sharded index in directory path with shards shards.
+// shards may be 0 to open an existing index with the layout in its shard manifest.
+//	s := gongt.NewSharded("index Path", 4).SetDimension(128).Open()
+func NewSharded(path string, shards int) *Sharded {
+	return &Sharded{
+		path: path,
+		size: shards,
+		prop: New(path).prop,
+		emu:  &sync.Mutex{},
+	}
+}
+
+// SetDimension sets vector dimension of every shard
+func (s *Sharded) SetDimension(dimension int) *Sharded {
+	s.prop.Dimension = dimension
+	return s
+}
+
+// SetCreationEdgeSize sets creation edge size of every shard
+func (s *Sharded) SetCreationEdgeSize(size int) *Sharded {
+	s.prop.CreationEdgeSize = size
+	return s
+}
+
+// SetSearchEdgeSize sets search edge size of every shard
+func (s *Sharded) SetSearchEdgeSize(size int) *Sharded {
+	s.prop.SearchEdgeSize = size
+	return s
+}
+
+// SetObjectType sets object type of every shard
+func (s *Sharded) SetObjectType(ot ObjectType) *Sharded {
+	s.prop.ObjectType = ot
+	return s
+}
+
+// SetDistanceType sets distance type of every shard
+func (s *Sharded) SetDistanceType(dt DistanceType) *Sharded {
+	s.prop.DistanceType = dt
+	return s
+}
+
+// SetWriteAheadLog enables write-ahead log of every shard
+func (s *Sharded) SetWriteAheadLog(enabled bool) *Sharded {
+	s.prop.WriteAheadLog = enabled
+	return s
+}
+
+// Open opens every shard. A new index directory gets shard subdirectories
+// and the shard manifest recording them.
+func (s *Sharded) Open() *Sharded {
+	dirs, err := s.layout()
+	if err != nil {
+		s.addError(err)
+		return s
+	}
+
+	s.shards = make([]*NGT, len(dirs))
+	for i, dir := range dirs {
+		n := New("")
+		n.prop = s.prop
+		n.prop.IndexPath = filepath.Join(s.path, dir)
+		s.shards[i] = n
+	}
+	s.each(func(n *NGT) error {
+		if errs := n.Open().GetErrors(); len(errs) > 0 {
+			return errs[0]
+		}
+		return nil
+	})
+	return s
+}
+
+// layout returns shard subdirectories from the shard manifest, writing it for a new index.
+func (s *Sharded) layout() ([]string, error) {
+	m, err := ngtfile.ReadShardManifest(s.path)
+	if err == nil {
+		if s.size > 0 && s.size != len(m.Dirs) {
+			return nil, fmt.Errorf("%s has %d shards, wanted %d", s.path, len(m.Dirs), s.size)
+		}
+		return m.Dirs, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if s.size <= 0 {
+		return nil, ErrNoShards
+	}
+	m = &ngtfile.ShardManifest{GongtVersion: Version, Dirs: make([]string, s.size)}
+	for i := range m.Dirs {
+		m.Dirs[i] = fmt.Sprintf("shard-%03d", i)
+	}
+	if err = os.MkdirAll(s.path, 0755); err != nil {
+		return nil, err
+	}
+	if err = ngtfile.WriteShardManifest(s.path, m); err != nil {
+		return nil, err
+	}
+	return m.Dirs, syncDir(s.path)
+}
+
+// Shards returns the number of shards.
+func (s *Sharded) Shards() int {
+	return len(s.shards)
+}
+
+// Shard returns NGT index of shard i, whose ids are local to the shard.
+func (s *Sharded) Shard(i int) *NGT {
+	return s.shards[i]
+}
+
+// globalID returns id of object id in shard i.
+func (s *Sharded) globalID(i, id int) int {
+	return id*len(s.shards) + i
+}
+
+// locate returns shard holding object id and its local id.
+func (s *Sharded) locate(id int) (*NGT, int, error) {
+	if len(s.shards) == 0 || id < len(s.shards) {
+		return nil, 0, ErrShardID
+	}
+	return s.shards[id%len(s.shards)], id / len(s.shards), nil
+}
+
+// Insert stores vec in the next shard and returns its id.
+// This only stores not indexing, you must call CreateIndex and SaveIndex.
+func (s *Sharded) Insert(vec []float64) (int, error) {
+	if len(s.shards) == 0 {
+		s.addError(ErrNoShards)
+		return 0, ErrNoShards
+	}
+	i := int((atomic.AddUint64(&s.next, 1) - 1) % uint64(len(s.shards)))
+	id, err := s.shards[i].Insert(vec)
+	if err != nil {
+		s.addError(err)
+		return 0, err
+	}
+	return s.globalID(i, id), nil
+}
+
+// BulkInsert returns ids of stored vectors.
+// This only stores not indexing, you must call CreateIndex and SaveIndex.
+func (s *Sharded) BulkInsert(vecs [][]float64) ([]int, []error) {
+	ids := make([]int, 0, len(vecs))
+	errs := make([]error, 0, len(vecs))
+	for _, vec := range vecs {
+		if id, err := s.Insert(vec); err == nil {
+			ids = append(ids, id)
+		} else {
+			errs = append(errs, err)
+		}
+	}
+	return ids, errs
+}
+
+// CreateIndex creates index of every shard in parallel.
+func (s *Sharded) CreateIndex(poolSize int) error {
+	return s.each(func(n *NGT) error {
+		return n.CreateIndex(poolSize)
+	})
+}
+
+// SaveIndex stores every shard in parallel.
+func (s *Sharded) SaveIndex() error {
+	return s.each(func(n *NGT) error {
+		return n.SaveIndex()
+	})
+}
+
+// CreateAndSaveIndex call CreateIndex and SaveIndex in a row.
+func (s *Sharded) CreateAndSaveIndex(poolSize int) error {
+	if err := s.CreateIndex(poolSize); err != nil {
+		return err
+	}
+	return s.SaveIndex()
+}
+
+// Search searches every shard in parallel and returns the nearest size results of all,
+// empty if size is not positive.
+func (s *Sharded) Search(vec []float64, size int, epsilon float64) ([]SearchResult, error) {
+	if size <= 0 {
+		return []SearchResult{}, nil
+	}
+	results := make([][]SearchResult, len(s.shards))
+	err := s.eachShard(func(i int, n *NGT) error {
+		res, err := n.Search(vec, size, epsilon)
+		for j := range res {
+			res[j].ID = s.globalID(i, res[j].ID)
+		}
+		results[i] = res
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make([]SearchResult, 0, len(s.shards)*size)
+	for _, res := range results {
+		merged = append(merged, res...)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].Distance != merged[j].Distance {
+			return merged[i].Distance < merged[j].Distance
+		}
+		return merged[i].ID < merged[j].ID
+	})
+	if len(merged) > size {
+		merged = merged[:size]
+	}
+	return merged, nil
+}
+
+// Remove removes object id from its shard.
+func (s *Sharded) Remove(id int) error {
+	n, local, err := s.locate(id)
+	if err == nil {
+		err = n.Remove(local)
+	}
+	if err != nil {
+		s.addError(err)
+		return err
+	}
+	return nil
+}
+
+// GetVector returns vector of object id.
+func (s *Sharded) GetVector(id int) ([]float64, error) {
+	n, local, err := s.locate(id)
+	if err != nil {
+		s.addError(err)
+		return nil, err
+	}
+	vec, err := n.GetVector(local)
+	if err != nil {
+		s.addError(err)
+		return nil, err
+	}
+	return vec, nil
+}
+
+// Len returns the number of live objects in every shard.
+func (s *Sharded) Len() int {
+	size := 0
+	for _, n := range s.shards {
+		size += n.Len()
+	}
+	return size
+}
+
+// Close closes every shard.
+func (s *Sharded) Close() {
+	for _, n := range s.shards {
+		n.Close()
+	}
+}
+
+// GetErrors returns errors
+func (s *Sharded) GetErrors() []error {
+	s.emu.Lock()
+	defer s.emu.Unlock()
+	return s.errs
+}
+
+func (s *Sharded) addError(err error) {
+	s.emu.Lock()
+	s.errs = append(s.errs, err)
+	s.emu.Unlock()
+}
+
+// each calls fn with every shard in parallel, see eachShard.
+func (s *Sharded) each(fn func(n *NGT) error) error {
+	return s.eachShard(func(_ int, n *NGT) error {
+		return fn(n)
+	})
+}
+
+// eachShard calls fn with every shard in parallel and returns the error of the lowest shard.
+// Every error is recorded.
+func (s *Sharded) eachShard(fn func(i int, n *NGT) error) error {
+	errs := make([]error, len(s.shards))
+	wg := &sync.WaitGroup{}
+	for i, n := range s.shards {
+		wg.Add(1)
+		go func(i int, n *NGT) {
+			defer wg.Done()
+			errs[i] = fn(i, n)
+		}(i, n)
+	}
+	wg.Wait()
+
+	var first error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("shard %d: %v", i, err)
+		s.addError(err)
+		if first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,125 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/yahoojapan/gongt/internal/ngtfile"
+)
+
+func TestSharded(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestSharded(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	indexPath := filepath.Join(tmpdir, "index")
+	s := NewSharded(indexPath, 3).SetObjectType(Uint8).SetDimension(6).Open()
+	if errs := s.GetErrors(); len(errs) > 0 {
+		t.Fatalf("Unexpected error: TestSharded(%v)", errs)
+	}
+	vectors := [][]float64{
+		{1, 0, 0, 0, 0, 0},
+		{0, 1, 0, 0, 0, 0},
+		{0, 0, 1, 0, 0, 0},
+		{0, 0, 0, 1, 0, 0},
+		{0, 0, 0, 0, 1, 0},
+		{0, 0, 0, 0, 0, 1},
+	}
+	ids, errs := s.BulkInsert(vectors)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected error: TestSharded(%v)", errs)
+	}
+	// round-robin over 3 shards, local ids start at 1
+	if want := []int{3, 4, 5, 6, 7, 8}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("TestSharded(ids): %v, wanted: %v", ids, want)
+	}
+	if err := s.CreateAndSaveIndex(poolSize); err != nil {
+		t.Errorf("Unexpected error: TestSharded(%v)", err)
+	}
+
+	for i, vec := range vectors {
+		result, err := s.Search(vec, 3, DefaultEpsilon)
+		if err != nil {
+			t.Errorf("Unexpected error: TestSharded(%v)", err)
+			continue
+		}
+		if len(result) != 3 || result[0].ID != ids[i] || result[0].Distance != 0 {
+			t.Errorf("TestSharded(%v): %v, wanted nearest: %v", vec, result, ids[i])
+		}
+		got, err := s.GetVector(ids[i])
+		if err != nil {
+			t.Errorf("Unexpected error: TestSharded(%v)", err)
+		}
+		if !reflect.DeepEqual(got, vec) {
+			t.Errorf("TestSharded(%v): %v, wanted: %v", ids[i], got, vec)
+		}
+	}
+
+	for _, size := range []int{0, -1} {
+		if result, err := s.Search(vectors[0], size, DefaultEpsilon); err != nil || len(result) != 0 {
+			t.Errorf("TestSharded(size %v): %v %v, wanted: []", size, result, err)
+		}
+	}
+
+	if err := s.Remove(ids[0]); err != nil {
+		t.Errorf("Unexpected error: TestSharded(%v)", err)
+	}
+	if err := s.Remove(2); err != ErrShardID {
+		t.Errorf("TestSharded(2): %v, wanted: %v", err, ErrShardID)
+	}
+	if err := s.SaveIndex(); err != nil {
+		t.Errorf("Unexpected error: TestSharded(%v)", err)
+	}
+	s.Close()
+
+	m, err := ngtfile.ReadShardManifest(indexPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: TestSharded(%v)", err)
+	}
+	if want := []string{"shard-000", "shard-001", "shard-002"}; !reflect.DeepEqual(m.Dirs, want) {
+		t.Errorf("TestSharded(manifest): %v, wanted: %v", m.Dirs, want)
+	}
+
+	// the layout is read from the manifest
+	s = NewSharded(indexPath, 0).Open()
+	defer s.Close()
+	if errs := s.GetErrors(); len(errs) > 0 {
+		t.Fatalf("Unexpected error: TestSharded(%v)", errs)
+	}
+	if got := s.Shards(); got != 3 {
+		t.Errorf("TestSharded(shards): %v, wanted: %v", got, 3)
+	}
+	if got := s.Len(); got != len(vectors)-1 {
+		t.Errorf("TestSharded(len): %v, wanted: %v", got, len(vectors)-1)
+	}
+
+	if errs := NewSharded(indexPath, 2).Open().GetErrors(); len(errs) == 0 {
+		t.Errorf("TestSharded(2 shards): no error, wanted: error")
+	}
+	if errs := NewSharded(filepath.Join(tmpdir, "new"), 0).Open().GetErrors(); len(errs) == 0 || errs[0] != ErrNoShards {
+		t.Errorf("TestSharded(0 shards): %v, wanted: %v", errs, ErrNoShards)
+	}
+}
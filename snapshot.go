@@ -0,0 +1,211 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yahoojapan/gongt/internal/ngtfile"
+)
+
+// Snapshot writes a consistent copy of default NGT index into dst
+func Snapshot(dst string) error {
+	return Get().Snapshot(dst)
+}
+
+// Snapshot writes a consistent copy of NGT index into dst.
+// The read lock is held only while NGT writes the index, so searches keep running.
+func (n *NGT) Snapshot(dst string) error {
+	if err := n.flushStaging(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		n.errs = append(n.errs, err)
+		return err
+	}
+
+	n.mu.RLock()
+	err := n.saveTo(dst)
+	n.mu.RUnlock()
+	if err != nil {
+		n.errs = append(n.errs, err)
+		return err
+	}
+	return nil
+}
+
+// SnapshotArchive writes a consistent copy of default NGT index to w as tar+gzip
+func SnapshotArchive(w io.Writer) error {
+	return Get().SnapshotArchive(w)
+}
+
+// SnapshotArchive writes a consistent copy of NGT index to w as tar+gzip.
+func (n *NGT) SnapshotArchive(w io.Writer) error {
+	tmp, err := ioutil.TempDir("", "gongt-snapshot")
+	if err != nil {
+		n.errs = append(n.errs, err)
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err = n.Snapshot(tmp); err != nil {
+		return err
+	}
+	if err = archiveDir(tmp, w); err != nil {
+		n.errs = append(n.errs, err)
+		return err
+	}
+	return nil
+}
+
+// Restore unpacks tar+gzip archive written by SnapshotArchive into path and opens it.
+// path must not exist or be empty. The archive is unpacked into a temporary directory
+// and moved to path only if it holds an index which Verify accepts, warnings aside.
+func Restore(r io.Reader, path string) (*NGT, error) {
+	path = filepath.Clean(path)
+	if infos, err := ioutil.ReadDir(path); err == nil && len(infos) > 0 {
+		return nil, fmt.Errorf("restore: %s is not empty", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	// Open removes the temporary directory if restoring is interrupted
+	tmp, err := ioutil.TempDir(filepath.Dir(path), filepath.Base(path)+tmpIndexSuffix)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	if err = os.Chmod(tmp, 0755); err != nil {
+		return nil, err
+	}
+	if err = unarchiveDir(r, tmp); err != nil {
+		return nil, err
+	}
+	if err = checkRestored(tmp); err != nil {
+		return nil, err
+	}
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+
+	n := New(path).Open()
+	if errs := n.GetErrors(); len(errs) > 0 {
+		n.Close()
+		return nil, errs[0]
+	}
+	return n, nil
+}
+
+// checkRestored returns error unless dir holds an index written by SnapshotArchive.
+func checkRestored(dir string) error {
+	for _, name := range []string{ngtfile.PropertyFile, ngtfile.ManifestFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("restore: archive has no %s", name)
+		}
+	}
+	for _, err := range ngtfile.Verify(dir) {
+		if _, ok := err.(*ngtfile.Warning); !ok {
+			return fmt.Errorf("restore: %w", err)
+		}
+	}
+	return nil
+}
+
+func archiveDir(dir string, w io.Writer) error {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, info := range infos {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		if err = tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		f, err := os.Open(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func unarchiveDir(r io.Reader, dir string) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		// index directory is flat, anything else is not written by SnapshotArchive
+		if hdr.Name != filepath.Base(hdr.Name) || strings.HasPrefix(hdr.Name, ".") {
+			return fmt.Errorf("restore: unexpected entry %q", hdr.Name)
+		}
+		f, err := os.OpenFile(filepath.Join(dir, hdr.Name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return syncDir(dir)
+}
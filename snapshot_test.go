@@ -0,0 +1,122 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+const example = "./assets/example"
+
+var exampleQuery = []float64{12, 17, 21, 18, 17, 31, 33, 25, 26, 19, 42, 31, 25, 26, 49, 30, 19, 23, 29, 29, 22, 19, 28, 27, 28, 19, 13, 12, 25, 21, 25, 21, 35, 12, 44, 36, 19, 49, 104, 33, 29, 77, 43, 36, 28, 44, 90, 46, 52, 37, 65, 42, 33, 40, 104, 103, 44, 26, 50, 43, 18, 20, 48, 68, 28, 16, 104, 27, 6, 36, 98, 327, 53, 81, 40, 36, 61, 104, 44, 27, 42, 84, 55, 54, 49, 53, 28, 27, 103, 42, 27, 28, 24, 53, 60, 66, 7, 42, 14, 6, 32, 69, 15, 3, 4, 79, 27, 7, 30, 82, 26, 3, 15, 27, 18, 6, 19, 52, 21, 16, 104, 72, 30, 40, 22, 36, 19, 22}
+
+func TestSnapshot(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestSnapshot(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	ngt := New(example).Open()
+	defer ngt.Close()
+	want, err := ngt.Search(exampleQuery, 10, DefaultEpsilon)
+	if err != nil {
+		t.Errorf("Unexpected error: TestSnapshot(%v)", err)
+	}
+
+	dst := path.Join(tmpdir, "snapshot")
+	if err := ngt.Snapshot(dst); err != nil {
+		t.Errorf("Unexpected error: TestSnapshot(%v)", err)
+	}
+	if errs := Verify(dst); len(errs) > 0 {
+		t.Errorf("Unexpected error: TestSnapshot(%v)", errs)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := ngt.SnapshotArchive(buf); err != nil {
+		t.Errorf("Unexpected error: TestSnapshot(%v)", err)
+	}
+	restored, err := Restore(buf, path.Join(tmpdir, "restored"))
+	if err != nil {
+		t.Fatalf("Unexpected error: TestSnapshot(%v)", err)
+	}
+	defer restored.Close()
+
+	got, err := restored.Search(exampleQuery, 10, DefaultEpsilon)
+	if err != nil {
+		t.Errorf("Unexpected error: TestSnapshot(%v)", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TestSnapshot: %v, wanted: %v", got, want)
+	}
+
+	if _, err := Restore(bytes.NewReader(nil), path.Join(tmpdir, "restored")); err == nil {
+		t.Errorf("TestSnapshot: Restore into non-empty directory succeeded")
+	}
+}
+
+func TestRestoreInvalid(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestRestoreInvalid(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	archive := func(files map[string]string) *bytes.Buffer {
+		buf := new(bytes.Buffer)
+		gw := gzip.NewWriter(buf)
+		tw := tar.NewWriter(gw)
+		for name, body := range files {
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(body)), Typeflag: tar.TypeReg})
+			tw.Write([]byte(body))
+		}
+		tw.Close()
+		gw.Close()
+		return buf
+	}
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"empty", map[string]string{}},
+		{"no prf", map[string]string{"obj": "not an index"}},
+		{"broken", map[string]string{"prf": "Dimension\t6\nObjectType\tFloat-4\n", "manifest": "", "obj": "x", "grp": "y"}},
+	}
+	for _, tt := range tests {
+		dst := path.Join(tmpdir, "restored")
+		if n, err := Restore(archive(tt.files), dst); err == nil {
+			n.Close()
+			t.Errorf("TestRestoreInvalid(%v): restored, wanted error", tt.name)
+		}
+		if _, err := os.Stat(dst); !os.IsNotExist(err) {
+			t.Errorf("TestRestoreInvalid(%v): %v exists", tt.name, dst)
+		}
+		os.RemoveAll(dst)
+	}
+	if infos, _ := ioutil.ReadDir(tmpdir); len(infos) > 0 {
+		t.Errorf("TestRestoreInvalid: %v left behind", infos[0].Name())
+	}
+}
@@ -0,0 +1,206 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"runtime"
+	"sort"
+	"time"
+)
+
+// staging holds objects inserted into the repository but not indexed yet.
+// NGT search does not reach them, so they are searched by brute force.
+// It is modified under write lock of NGT and read under read lock.
+type staging struct {
+	objects map[uint][]float32
+	// removed are staged objects removed before being indexed,
+	// they are removed from the graph by the next createIndex
+	removed  map[uint]struct{}
+	size     int
+	interval time.Duration
+	kick     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newStaging(size int, interval time.Duration) *staging {
+	return &staging{
+		objects:  make(map[uint][]float32),
+		removed:  make(map[uint]struct{}),
+		size:     size,
+		interval: interval,
+		kick:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// add stages object id and wakes the merger when size objects are staged.
+func (s *staging) add(id uint, obj []float32) {
+	s.objects[id] = obj
+	if len(s.objects) >= s.size {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// remove unstages object id and reports whether it was staged.
+func (s *staging) remove(id uint) bool {
+	if _, ok := s.objects[id]; !ok {
+		return false
+	}
+	delete(s.objects, id)
+	s.removed[id] = struct{}{}
+	return true
+}
+
+// isRemoved reports whether id was removed while staged and is still in the repository.
+func (s *staging) isRemoved(id uint) bool {
+	_, ok := s.removed[id]
+	return ok
+}
+
+// pending reports whether createIndex has anything to do.
+func (s *staging) pending() bool {
+	return len(s.objects) > 0 || len(s.removed) > 0
+}
+
+// reset empties staging after createIndex and returns the objects to remove.
+func (s *staging) reset() map[uint]struct{} {
+	removed := s.removed
+	s.objects = make(map[uint][]float32)
+	s.removed = make(map[uint]struct{})
+	return removed
+}
+
+// search returns the nearest size staged objects to query within radius, no limit if radius is negative.
+func (s *staging) search(dt DistanceType, query []float32, size int, radius float32) []StrictSearchResult {
+	result := make([]StrictSearchResult, 0, len(s.objects))
+	for id, obj := range s.objects {
+		d := distance(dt, query, obj)
+		if radius >= 0 && d > radius {
+			continue
+		}
+		result = append(result, StrictSearchResult{ID: uint32(id), Distance: d})
+	}
+	sortResults(result)
+	if len(result) > size {
+		result = result[:size]
+	}
+	return result
+}
+
+// sortResults sorts results by distance and id, failed results last.
+func sortResults(result []StrictSearchResult) {
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if (a.Error != nil) != (b.Error != nil) {
+			return b.Error != nil
+		}
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+		return a.ID < b.ID
+	})
+}
+
+// SetStaging enables staging area of the default NGT index
+func SetStaging(size int, interval time.Duration) *NGT {
+	return Get().SetStaging(size, interval)
+}
+
+// SetStaging enables staging area. Inserted objects are searched by brute force
+// until a background goroutine indexes them, when size objects are staged or
+// every interval if it is positive. CreateIndex, SaveIndex, SaveTo and Snapshot
+// index staged objects at once.
+// Indexing holds the write lock, so a search waits for one merge of about size objects
+// at most instead of a CreateIndex of every insert since the last one. Keep size small.
+// Set 0 to disable.
+//	ngt := gongt.New("index Path").SetStaging(1000, time.Second).Open()
+func (n *NGT) SetStaging(size int, interval time.Duration) *NGT {
+	n.mu.Lock()
+	n.prop.StagingSize = size
+	n.prop.StagingInterval = interval
+	n.mu.Unlock()
+
+	return n
+}
+
+// openStaging creates staging area and starts the goroutine indexing it if enabled,
+// caller must hold write lock. The merger of a previous Open is stopped.
+func (n *NGT) openStaging() {
+	if n.staged != nil {
+		// the merger may be waiting for the lock held by the caller
+		close(n.staged.stop)
+		n.staged = nil
+	}
+	if n.prop.StagingSize > 0 {
+		n.staged = newStaging(n.prop.StagingSize, n.prop.StagingInterval)
+		go n.mergeStaging(n.staged)
+	}
+}
+
+func (n *NGT) mergeStaging(s *staging) {
+	defer close(s.done)
+	var tick <-chan time.Time
+	if s.interval > 0 {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.kick:
+		case <-tick:
+		}
+		n.flushStaging()
+	}
+}
+
+// flushStaging indexes staged objects if any.
+func (n *NGT) flushStaging() error {
+	n.mu.RLock()
+	pending := n.staged != nil && n.staged.pending()
+	n.mu.RUnlock()
+	if !pending {
+		return nil
+	}
+	return n.CreateIndex(runtime.NumCPU())
+}
+
+// closeStaging stops the goroutine indexing staged objects, caller must not hold lock.
+func (n *NGT) closeStaging() {
+	if n.staged == nil {
+		return
+	}
+	close(n.staged.stop)
+	<-n.staged.done
+	n.mu.Lock()
+	n.staged = nil
+	n.mu.Unlock()
+}
+
+// stagedQuery converts vec into object as NGT stores it, vec must have Dimension elements at least.
+func (n *NGT) stagedQuery(vec []float64) []float32 {
+	return toObject(n.prop.ObjectType, vec[:n.prop.Dimension])
+}
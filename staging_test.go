@@ -0,0 +1,321 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func stagedLen(n *NGT) int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.staged == nil {
+		return 0
+	}
+	return len(n.staged.objects)
+}
+
+func TestStaging(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestStaging(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	indexPath := filepath.Join(tmpdir, "index")
+	n := New(indexPath).SetObjectType(Uint8).SetDimension(6).SetStaging(100, 0).Open()
+	if errs := n.GetErrors(); len(errs) > 0 {
+		t.Fatalf("Unexpected error: TestStaging(%v)", errs)
+	}
+	vectors := [][]float64{
+		{1, 0, 0, 0, 0, 0},
+		{0, 1, 0, 0, 0, 0},
+		{0, 0, 1, 0, 0, 0},
+		{0, 0, 0, 1, 0, 0},
+	}
+	ids, errs := n.BulkInsert(vectors)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected error: TestStaging(%v)", errs)
+	}
+	if got := stagedLen(n); got != len(vectors) {
+		t.Errorf("TestStaging(staged): %v, wanted: %v", got, len(vectors))
+	}
+
+	// staged objects are found before CreateIndex
+	for i, vec := range vectors {
+		result, err := n.Search(vec, 2, DefaultEpsilon)
+		if err != nil {
+			t.Errorf("Unexpected error: TestStaging(%v)", err)
+			continue
+		}
+		if len(result) != 2 || result[0].ID != ids[i] || result[0].Distance != 0 {
+			t.Errorf("TestStaging(%v): %v, wanted nearest: %v", vec, result, ids[i])
+		}
+	}
+
+	if err := n.Remove(ids[0]); err != nil {
+		t.Errorf("Unexpected error: TestStaging(%v)", err)
+	}
+	if result, _ := n.Search(vectors[0], 1, DefaultEpsilon); len(result) > 0 && result[0].ID == ids[0] {
+		t.Errorf("TestStaging(removed): %v, wanted without: %v", result, ids[0])
+	}
+
+	if err := n.SaveIndex(); err != nil {
+		t.Errorf("Unexpected error: TestStaging(%v)", err)
+	}
+	if got := stagedLen(n); got != 0 {
+		t.Errorf("TestStaging(saved): %v staged, wanted: 0", got)
+	}
+	for i, vec := range vectors[1:] {
+		result, err := n.Search(vec, len(vectors), DefaultEpsilon)
+		if err != nil {
+			t.Errorf("Unexpected error: TestStaging(%v)", err)
+			continue
+		}
+		seen := make(map[int]bool)
+		for _, r := range result {
+			if seen[r.ID] || r.ID == ids[0] {
+				t.Errorf("TestStaging(%v): %v has duplicated or removed id", vec, result)
+			}
+			seen[r.ID] = true
+		}
+		if len(result) == 0 || result[0].ID != ids[i+1] {
+			t.Errorf("TestStaging(%v): %v, wanted nearest: %v", vec, result, ids[i+1])
+		}
+	}
+	n.Close()
+
+	n = New(indexPath).Open()
+	defer n.Close()
+	if got := n.Len(); got != len(vectors)-1 {
+		t.Errorf("TestStaging(reopen): %v, wanted: %v", got, len(vectors)-1)
+	}
+}
+
+func TestStagingMerge(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestStagingMerge(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	n := New(filepath.Join(tmpdir, "index")).SetObjectType(Uint8).SetDimension(6).SetStaging(2, 0).Open()
+	defer n.Close()
+	if _, errs := n.BulkInsert([][]float64{{1, 0, 0, 0, 0, 0}, {0, 1, 0, 0, 0, 0}}); len(errs) > 0 {
+		t.Fatalf("Unexpected error: TestStagingMerge(%v)", errs)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for stagedLen(n) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := stagedLen(n); got != 0 {
+		t.Errorf("TestStagingMerge: %v staged, wanted: 0", got)
+	}
+	if errs := n.GetErrors(); len(errs) > 0 {
+		t.Errorf("Unexpected error: TestStagingMerge(%v)", errs)
+	}
+}
+
+func TestStagingRemoved(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestStagingRemoved(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	n := New(filepath.Join(tmpdir, "index")).SetObjectType(Uint8).SetDimension(6).SetStaging(100, 0).Open()
+	defer n.Close()
+	indexed, err := n.Insert([]float64{1, 0, 0, 0, 0, 0})
+	if err != nil {
+		t.Fatalf("Unexpected error: TestStagingRemoved(%v)", err)
+	}
+	if err = n.CreateIndex(DefaultPoolSize); err != nil {
+		t.Fatalf("Unexpected error: TestStagingRemoved(%v)", err)
+	}
+	staged, err := n.Insert([]float64{0, 1, 0, 0, 0, 0})
+	if err != nil {
+		t.Fatalf("Unexpected error: TestStagingRemoved(%v)", err)
+	}
+
+	for _, id := range []int{indexed, staged} {
+		if err := n.Remove(id); err != nil {
+			t.Errorf("Unexpected error: TestStagingRemoved(%v)", err)
+		}
+		if vec, err := n.GetVector(id); err == nil {
+			t.Errorf("TestStagingRemoved(%v): %v, wanted error", id, vec)
+		}
+		if err := n.Remove(id); err == nil {
+			t.Errorf("TestStagingRemoved(%v): removed twice", id)
+		}
+		if result, _ := n.Search([]float64{1, 1, 0, 0, 0, 0}, 2, DefaultEpsilon); len(result) > 0 && result[0].ID == id {
+			t.Errorf("TestStagingRemoved(%v): %v, wanted without: %v", id, result, id)
+		}
+	}
+	if got := n.Len(); got != 0 {
+		t.Errorf("TestStagingRemoved(len): %v, wanted: 0", got)
+	}
+	if err := n.CreateIndex(DefaultPoolSize); err != nil {
+		t.Errorf("Unexpected error: TestStagingRemoved(%v)", err)
+	}
+	if got := n.Len(); got != 0 {
+		t.Errorf("TestStagingRemoved(indexed): %v, wanted: 0", got)
+	}
+}
+
+func TestStagingDimension(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestStagingDimension(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	n := New(filepath.Join(tmpdir, "index")).SetDimension(6).SetStaging(100, 0).Open()
+	defer n.Close()
+	for _, vec := range [][]float64{nil, {1, 2, 3}} {
+		if _, err := n.Search(vec, 1, DefaultEpsilon); !errors.Is(err, ErrDimension) {
+			t.Errorf("TestStagingDimension(%v): %v, wanted: %v", vec, err, ErrDimension)
+		}
+	}
+}
+
+func TestStagingReopen(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestStagingReopen(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	before := runtime.NumGoroutine()
+	n := New(filepath.Join(tmpdir, "index")).SetDimension(6).SetStaging(100, 0).Open()
+	first := n.staged
+	n.Open()
+	if errs := n.GetErrors(); len(errs) > 0 {
+		t.Fatalf("Unexpected error: TestStagingReopen(%v)", errs)
+	}
+	select {
+	case <-first.done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("TestStagingReopen: the first merger is still running")
+	}
+	n.Close()
+	if got := runtime.NumGoroutine(); got > before {
+		t.Errorf("TestStagingReopen(goroutines): %v, wanted: %v", got, before)
+	}
+}
+
+// BenchmarkSearchDuringInsert reports p50 and p99 search latency while another goroutine keeps inserting.
+// Idle is the baseline without inserts. In CreateIndex the inserter calls CreateIndex every batch
+// objects and searches wait for it, so p99 is about the time to index a batch.
+// With Staging inserted objects are searchable at once and merged every staged objects,
+// so p99 is expected to be about the time to index staged objects, between the other two.
+func BenchmarkSearchDuringInsert(b *testing.B) {
+	for _, mode := range []string{"Idle", "CreateIndex", "Staging"} {
+		b.Run(mode, func(b *testing.B) {
+			benchmarkSearchDuringInsert(b, mode)
+		})
+	}
+}
+
+func benchmarkSearchDuringInsert(b *testing.B, mode string) {
+	const (
+		dim    = 64
+		batch  = 1000
+		staged = 100
+	)
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		b.Fatalf("Unexpected error: BenchmarkSearchDuringInsert(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	rnd := rand.New(rand.NewSource(1))
+	vector := func() []float64 {
+		vec := make([]float64, dim)
+		for i := range vec {
+			vec[i] = rnd.Float64()
+		}
+		return vec
+	}
+
+	n := New(filepath.Join(tmpdir, "index")).SetDimension(dim)
+	if mode == "Staging" {
+		n.SetStaging(staged, 0)
+	}
+	n.Open()
+	defer n.Close()
+	for i := 0; i < 10000; i++ {
+		n.Insert(vector())
+	}
+	if err := n.CreateIndex(DefaultPoolSize); err != nil {
+		b.Fatalf("Unexpected error: BenchmarkSearchDuringInsert(%v)", err)
+	}
+	queries := make([][]float64, 100)
+	for i := range queries {
+		queries[i] = vector()
+	}
+	inserts := make([][]float64, 100000)
+	for i := range inserts {
+		inserts[i] = vector()
+	}
+
+	stop := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	if mode != "Idle" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i, vec := range inserts {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				n.Insert(vec)
+				if mode == "CreateIndex" && (i+1)%batch == 0 {
+					n.CreateIndex(DefaultPoolSize)
+				}
+			}
+		}()
+	}
+
+	latencies := make([]time.Duration, b.N)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		n.Search(queries[i%len(queries)], 10, DefaultEpsilon)
+		latencies[i] = time.Since(start)
+	}
+	b.StopTimer()
+	close(stop)
+	wg.Wait()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.ReportMetric(float64(latencies[b.N/2].Nanoseconds()), "p50-ns")
+	b.ReportMetric(float64(latencies[b.N*99/100].Nanoseconds()), "p99-ns")
+}
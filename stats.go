@@ -0,0 +1,139 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/yahoojapan/gongt/internal/ngtfile"
+)
+
+// IndexStats is the summary of NGT index.
+// Objects and RemovedObjects reflect the index in memory, while graph, tree and
+// file statistics are read from IndexPath and reflect the last SaveIndex.
+type IndexStats struct {
+	Objects        int
+	RemovedObjects int
+
+	Nodes            int
+	Edges            int
+	MinOutDegree     int
+	MaxOutDegree     int
+	AverageOutDegree float64
+	UnreachableNodes int
+
+	// TreeLeafNodes is the number of leaf node slots of the tree
+	TreeLeafNodes int
+
+	// FileSizes is on-disk bytes of each file in IndexPath
+	FileSizes map[string]int64
+}
+
+// Stats returns statistics of NGT index.
+func Stats() (IndexStats, error) {
+	return Get().Stats()
+}
+
+// Stats returns statistics of NGT index.
+// Graph statistics are read from IndexPath, so in-memory index returns ErrNoIndexPath.
+func (n *NGT) Stats() (IndexStats, error) {
+	s := IndexStats{
+		FileSizes: make(map[string]int64),
+	}
+	if n.prop.IndexPath == "" {
+		n.errs = append(n.errs, ErrNoIndexPath)
+		return s, ErrNoIndexPath
+	}
+
+	n.mu.RLock()
+	n.rangeObjects(func(uint, []float32) bool {
+		s.Objects++
+		return true
+	})
+	if n.repoSize > 0 {
+		s.RemovedObjects = int(n.repoSize) - 1 - s.Objects
+	}
+	n.mu.RUnlock()
+
+	// keep SaveIndex from swapping IndexPath while reading it
+	n.smu.Lock()
+	defer n.smu.Unlock()
+	if err := readFileStats(n.prop.IndexPath, &s); err != nil {
+		n.errs = append(n.errs, err)
+		return s, err
+	}
+	return s, nil
+}
+
+func readFileStats(path string, s *IndexStats) error {
+	names := make([]string, 0, len(ngtfile.Files)+2)
+	names = append(names, ngtfile.Files...)
+	for _, name := range append(names, ngtfile.ManifestFile, WALFile) {
+		info, err := os.Stat(filepath.Join(path, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		s.FileSizes[name] = info.Size()
+	}
+
+	f, err := os.Open(filepath.Join(path, ngtfile.GraphFile))
+	if err != nil {
+		return err
+	}
+	g, err := ngtfile.ReadGraph(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	s.MinOutDegree = -1
+	for _, node := range g.Nodes {
+		if node == nil {
+			continue
+		}
+		degree := len(node.Edges)
+		s.Nodes++
+		s.Edges += degree
+		if s.MinOutDegree < 0 || degree < s.MinOutDegree {
+			s.MinOutDegree = degree
+		}
+		if degree > s.MaxOutDegree {
+			s.MaxOutDegree = degree
+		}
+	}
+	if s.Nodes > 0 {
+		s.AverageOutDegree = float64(s.Edges) / float64(s.Nodes)
+	} else {
+		s.MinOutDegree = 0
+	}
+	s.UnreachableNodes = len(g.Unreachable())
+
+	// tre begins with the repository of leaf nodes
+	size, err := ngtfile.ReadRepositorySize(filepath.Join(path, ngtfile.TreeFile))
+	if err != nil {
+		return err
+	}
+	if size > 0 {
+		s.TreeLeafNodes = int(size) - 1
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	tests := []struct {
+		remove []int
+		want   IndexStats
+	}{
+		{
+			[]int{},
+			IndexStats{
+				Objects:          6,
+				Nodes:            6,
+				Edges:            30,
+				MinOutDegree:     5,
+				MaxOutDegree:     5,
+				AverageOutDegree: 5,
+				TreeLeafNodes:    1,
+				FileSizes:        map[string]int64{"grp": 297, "obj": 51, "prf": 343, "tre": 82},
+			},
+		},
+		{
+			// graph statistics are of the saved index
+			[]int{2, 5},
+			IndexStats{
+				Objects:          4,
+				RemovedObjects:   2,
+				Nodes:            6,
+				Edges:            30,
+				MinOutDegree:     5,
+				MaxOutDegree:     5,
+				AverageOutDegree: 5,
+				TreeLeafNodes:    1,
+				FileSizes:        map[string]int64{"grp": 297, "obj": 51, "prf": 343, "tre": 82},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tmpdir, err := ioutil.TempDir("", "tmpdir")
+		if err != nil {
+			t.Errorf("Unexpected error: TestStats(%v)", err)
+		}
+		defer os.RemoveAll(tmpdir)
+
+		if err := exec.Command("cp", "-r", index, tmpdir).Run(); err != nil {
+			t.Errorf("Unexpected error: TestStats(%v)", err)
+		}
+
+		ngt := New(path.Join(tmpdir, "index")).Open()
+		for _, id := range tt.remove {
+			if err := ngt.Remove(id); err != nil {
+				t.Errorf("Unexpected error: TestStats(%v)", err)
+			}
+		}
+		got, err := ngt.Stats()
+		if err != nil {
+			t.Errorf("Unexpected error: TestStats(%v)", err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TestStats(%v): %+v, wanted: %+v", tt.remove, got, tt.want)
+		}
+		ngt.Close()
+	}
+}
+
+func TestStatsInMemory(t *testing.T) {
+	ngt := OpenInMemory(Property{Dimension: 6})
+	defer ngt.Close()
+	if _, err := ngt.Stats(); err != ErrNoIndexPath {
+		t.Errorf("TestStatsInMemory: %v, wanted: %v", err, ErrNoIndexPath)
+	}
+}
@@ -0,0 +1,96 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import "context"
+
+type (
+	// Tracer starts spans around calls into NGT.
+	// It has the shape of OpenTelemetry trace.Tracer, so an adapter is a few lines.
+	Tracer interface {
+		// Start starts span name as a child of the span in ctx
+		Start(ctx context.Context, name string) (context.Context, Span)
+	}
+
+	// Span is a traced call into NGT
+	Span interface {
+		SetAttributes(attrs ...Attribute)
+		RecordError(err error)
+		End()
+	}
+
+	// Attribute is key and value attached to Span,
+	// value is int, float64, bool or string
+	Attribute struct {
+		Key   string
+		Value interface{}
+	}
+)
+
+const (
+	// SpanSearch is the name of spans around ngt_search_index
+	SpanSearch = "ngt_search_index"
+	// SpanInsert is the name of spans around ngt_insert_index
+	SpanInsert = "ngt_insert_index"
+	// SpanCreateIndex is the name of spans around ngt_create_index
+	SpanCreateIndex = "ngt_create_index"
+	// SpanSaveIndex is the name of spans around ngt_save_index
+	SpanSaveIndex = "ngt_save_index"
+)
+
+// SetTracer sets Tracer of the default NGT index
+func SetTracer(t Tracer) *NGT {
+	return Get().SetTracer(t)
+}
+
+// SetTracer sets Tracer receiving spans around ngt_search_index, ngt_insert_index,
+// ngt_create_index and ngt_save_index, nil to disable. Spans carry ngt.dimension and
+// ngt.k, ngt.epsilon and ngt.results for searches, ngt.pool_size for index creation
+// and ngt.path for saves. Use SearchContext to make search spans children of a request.
+func (n *NGT) SetTracer(t Tracer) *NGT {
+	n.mu.Lock()
+	n.tracer = t
+	n.mu.Unlock()
+
+	return n
+}
+
+// startSpan starts span name with attrs, nil if tracing is disabled.
+func (n *NGT) startSpan(ctx context.Context, name string, attrs ...Attribute) Span {
+	if n.tracer == nil {
+		return nil
+	}
+	_, span := n.tracer.Start(ctx, name)
+	span.SetAttributes(append([]Attribute{{"ngt.dimension", n.prop.Dimension}}, attrs...)...)
+	return span
+}
+
+// endSpan records err and attrs and ends span if it is not nil.
+func endSpan(span Span, err error, attrs ...Attribute) {
+	if span == nil {
+		return
+	}
+	if len(attrs) > 0 {
+		span.SetAttributes(attrs...)
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
@@ -0,0 +1,112 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type parentKey struct{}
+
+type recordingSpan struct {
+	name   string
+	parent interface{}
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+}
+
+func (s *recordingSpan) SetAttributes(attrs ...Attribute) {
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *recordingSpan) RecordError(err error) {
+	s.err = err
+}
+
+func (s *recordingSpan) End() {
+	s.ended = true
+}
+
+type recordingTracer struct {
+	spans []*recordingSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	s := &recordingSpan{name: name, parent: ctx.Value(parentKey{}), attrs: make(map[string]interface{})}
+	t.spans = append(t.spans, s)
+	return ctx, s
+}
+
+func TestTracer(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestTracer(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	tracer := new(recordingTracer)
+	n := New(filepath.Join(tmpdir, "index")).SetObjectType(Uint8).SetDimension(6).SetTracer(tracer).Open()
+	defer n.Close()
+	// Open saves the new index
+	tracer.spans = nil
+
+	if _, err := n.InsertCommit([]float64{1, 0, 0, 0, 0, 0}, poolSize); err != nil {
+		t.Errorf("Unexpected error: TestTracer(%v)", err)
+	}
+	ctx := context.WithValue(context.Background(), parentKey{}, "request")
+	if _, err := n.SearchContext(ctx, []float64{1, 0, 0, 0, 0, 0}, 3, 0.1); err != nil {
+		t.Errorf("Unexpected error: TestTracer(%v)", err)
+	}
+
+	tests := []struct {
+		name  string
+		attrs map[string]interface{}
+	}{
+		{SpanInsert, map[string]interface{}{"ngt.dimension": 6}},
+		{SpanCreateIndex, map[string]interface{}{"ngt.dimension": 6, "ngt.pool_size": poolSize}},
+		{SpanSaveIndex, nil},
+		{SpanSearch, map[string]interface{}{"ngt.dimension": 6, "ngt.k": 3, "ngt.epsilon": float64(float32(0.1)), "ngt.results": 1}},
+	}
+	if len(tracer.spans) != len(tests) {
+		t.Fatalf("TestTracer: %d spans, wanted: %d", len(tracer.spans), len(tests))
+	}
+	for i, tt := range tests {
+		s := tracer.spans[i]
+		if s.name != tt.name || !s.ended || s.err != nil {
+			t.Errorf("TestTracer(%v): %+v", tt.name, s)
+		}
+		if tt.attrs != nil && !reflect.DeepEqual(s.attrs, tt.attrs) {
+			t.Errorf("TestTracer(%v): %v, wanted: %v", tt.name, s.attrs, tt.attrs)
+		}
+	}
+	if s := tracer.spans[3]; s.parent != "request" {
+		t.Errorf("TestTracer(parent): %v, wanted: %v", s.parent, "request")
+	}
+	if _, ok := tracer.spans[2].attrs["ngt.path"]; !ok {
+		t.Errorf("TestTracer(%v): %v, wanted with ngt.path", SpanSaveIndex, tracer.spans[2].attrs)
+	}
+}
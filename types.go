@@ -0,0 +1,97 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package gongt provides implementation of Go API for https://github.com/yahoojapan/NGT
+package gongt
+
+import "time"
+
+type (
+	// SearchResult is struct for comfortable use in Go
+	SearchResult struct {
+		ID       int
+		Distance float64
+	}
+	// Property includes parameters for NGT
+	Property struct {
+		Dimension           int
+		CreationEdgeSize    int
+		SearchEdgeSize      int
+		ObjectType          ObjectType
+		DistanceType        DistanceType
+		IndexPath           string
+		BulkInsertChunkSize int
+		WriteAheadLog       bool
+		KeepPreviousIndex   bool
+		StagingSize         int
+		StagingInterval     time.Duration
+		SlowQueryThreshold  time.Duration
+	}
+)
+
+// ObjectType is alias of object type in NGT
+type ObjectType int
+
+// DistanceType is alias of distance type in NGT
+type DistanceType int
+
+const (
+	// ObjectNone is unknown object type
+	ObjectNone ObjectType = iota
+	// Uint8 is 8bit unsigned integer
+	Uint8
+	// Float is 32bit floating point number
+	Float
+
+	// DistanceNone is unknown distance type
+	DistanceNone DistanceType = iota - 1
+	// L1 is l1 norm
+	L1
+	// L2 is l2 norm
+	L2
+	// Angle is angle distance
+	Angle
+	// Hamming is hamming distance
+	Hamming
+	// Cosine is cosine distance
+	Cosine
+	// NormalizedAngle is angle distance with normalization
+	NormalizedAngle
+	// NormalizedCosine is cosine distance with normalization
+	NormalizedCosine
+
+	// DefaultDimension is 0
+	DefaultDimension = 0
+	// DefaultCreationEdgeSize is 10
+	DefaultCreationEdgeSize = 10
+	// DefaultSearchEdgeSize is 10
+	DefaultSearchEdgeSize = 40
+	// DefaultObjectType is Float
+	DefaultObjectType = Float
+	// DefaultDistanceType is L2
+	DefaultDistanceType = L2
+	// DefaultEpsilon is 0.01
+	DefaultEpsilon = 0.01
+	// DefaultBulkInsertChunkSize is 100
+	DefaultBulkInsertChunkSize = 100
+	// DefaultPoolSize is 1
+	DefaultPoolSize = 1
+
+	// Version is gongt version recorded in index manifest
+	Version = "v1.1.1"
+	// NGTVersion is NGT version gongt is built against
+	NGTVersion = "1.7.3"
+)
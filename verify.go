@@ -0,0 +1,51 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"fmt"
+
+	"github.com/yahoojapan/gongt/internal/ngtfile"
+)
+
+// VerifyWarning is a problem reported by Verify which may not break the index,
+// such as graph nodes unreachable from the first node.
+type VerifyWarning = ngtfile.Warning
+
+// Verify checks index directory and returns the problems found.
+// It compares files with the manifest written by SaveIndex, validates object
+// and graph repositories, and opens the index.
+func Verify(path string) []error {
+	errs := ngtfile.Verify(path)
+
+	prop, err := ngtfile.ReadProperty(path)
+	if err != nil {
+		return errs
+	}
+
+	n := OpenReadOnly(path)
+	defer n.Close()
+	if oerrs := n.GetErrors(); len(oerrs) > 0 {
+		return append(errs, oerrs...)
+	}
+	if dim, err := prop.Dimension(); err == nil && dim != n.GetDim() {
+		errs = append(errs, fmt.Errorf("%s: Dimension %d, opened index has %d", ngtfile.PropertyFile, dim, n.GetDim()))
+	}
+	return errs
+}
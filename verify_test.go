@@ -0,0 +1,88 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yahoojapan/gongt/internal/ngtfile"
+)
+
+func TestVerify(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestVerify(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	ngt := New(tmpdir).SetObjectType(Uint8).SetDimension(6).Open()
+	if _, errs := ngt.BulkInsertCommit([][]float64{
+		{1, 0, 0, 0, 0, 0},
+		{0, 1, 0, 0, 0, 0},
+		{0, 0, 1, 0, 0, 0},
+	}, poolSize); len(errs) > 0 {
+		t.Errorf("Unexpected error: TestVerify(%v)", errs)
+	}
+	ngt.Close()
+
+	if _, err := os.Stat(filepath.Join(tmpdir, ngtfile.ManifestFile)); err != nil {
+		t.Errorf("Unexpected error: TestVerify(%v)", err)
+	}
+	if errs := Verify(tmpdir); len(errs) > 0 {
+		t.Errorf("Unexpected error: TestVerify(%v)", errs)
+	}
+
+	if err := os.Truncate(filepath.Join(tmpdir, ngtfile.GraphFile), 20); err != nil {
+		t.Errorf("Unexpected error: TestVerify(%v)", err)
+	}
+	if errs := Verify(tmpdir); len(errs) == 0 {
+		t.Errorf("TestVerify: truncated %v is not reported", ngtfile.GraphFile)
+	}
+}
+
+func TestVerifyUnindexed(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestVerifyUnindexed(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	// an index saved between Insert and CreateIndex is valid
+	ngt := New(tmpdir).SetObjectType(Uint8).SetDimension(6).Open()
+	if _, err := ngt.Insert([]float64{1, 0, 0, 0, 0, 0}); err != nil {
+		t.Errorf("Unexpected error: TestVerifyUnindexed(%v)", err)
+	}
+	if err := ngt.SaveIndex(); err != nil {
+		t.Errorf("Unexpected error: TestVerifyUnindexed(%v)", err)
+	}
+	ngt.Close()
+
+	errs := Verify(tmpdir)
+	if len(errs) == 0 {
+		t.Errorf("TestVerifyUnindexed: no warning, wanted: objects have no node")
+	}
+	for _, err := range errs {
+		if _, ok := err.(*VerifyWarning); !ok {
+			t.Errorf("TestVerifyUnindexed: %v, wanted: warning", err)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type (
+	// wal is append-only log of mutations since the last SaveIndex.
+	//
+	// Each record is laid out in little endian as
+	//	length uint32 | crc32 uint32 | op uint8 | id uint32 | [dim uint32 | dim * float64]
+	// where length and crc32 cover the bytes after the crc32 field.
+	wal struct {
+		mu   *sync.Mutex
+		path string
+		f    *os.File
+	}
+	walRecord struct {
+		op  walOp
+		id  uint
+		vec []float64
+	}
+	walOp uint8
+)
+
+const (
+	walInsert walOp = iota + 1
+	walRemove
+	walCreateIndex
+
+	// WALFile is the name of write-ahead log in index directory
+	WALFile = "wal"
+)
+
+var (
+	// ErrWALCorrupted raises when write-ahead log has broken records before its end
+	ErrWALCorrupted = errors.New("write-ahead log is corrupted")
+)
+
+// openWAL replays write-ahead log in index directory and opens it for append.
+// Caller must hold write lock.
+func (n *NGT) openWAL() error {
+	w := &wal{
+		mu:   &sync.Mutex{},
+		path: filepath.Join(n.prop.IndexPath, WALFile),
+	}
+	f, err := os.OpenFile(w.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	w.f = f
+
+	valid, err := w.replay(func(rec walRecord) error {
+		switch rec.op {
+		case walInsert:
+			id, err := n.insert(rec.vec)
+			if err != nil {
+				return err
+			}
+			if id != rec.id {
+				return fmt.Errorf("write-ahead log replay: inserted id %d, recorded %d", id, rec.id)
+			}
+		case walRemove:
+			return n.remove(rec.id)
+		case walCreateIndex:
+			return n.createIndex(int(rec.id))
+		}
+		return nil
+	})
+	if err != nil && err != ErrWALCorrupted {
+		f.Close()
+		return err
+	}
+	// a torn record at the tail was never acknowledged to the caller,
+	// drop it so new records are appended after the last valid one
+	if err = f.Truncate(valid); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err = f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+
+	n.wal = w
+	return nil
+}
+
+// replay calls fn for every valid record and returns the offset after the last valid one.
+// A record running to the end of the log is a write torn by a crash and
+// ErrWALCorrupted is returned with the offset before it, so that it can be dropped.
+// A broken record followed by others is reported with its offset,
+// as dropping it would lose every record after it.
+func (w *wal) replay(fn func(walRecord) error) (int64, error) {
+	info, err := w.f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(w.f)
+	var offset int64
+	hdr := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, hdr); err != nil {
+			if err == io.EOF {
+				return offset, nil
+			}
+			return offset, ErrWALCorrupted
+		}
+		size := int64(binary.LittleEndian.Uint32(hdr[0:4]))
+		sum := binary.LittleEndian.Uint32(hdr[4:8])
+		end := offset + int64(len(hdr)) + size
+		if end > info.Size() {
+			return offset, ErrWALCorrupted
+		}
+		// broken is ErrWALCorrupted at the tail, the offset elsewhere
+		broken := func() error {
+			if end == info.Size() {
+				return ErrWALCorrupted
+			}
+			return fmt.Errorf("%w: broken record at offset %d", ErrWALCorrupted, offset)
+		}
+		if size < 5 {
+			return offset, broken()
+		}
+		body := make([]byte, size)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return offset, ErrWALCorrupted
+		}
+		if crc32.ChecksumIEEE(body) != sum {
+			return offset, broken()
+		}
+		rec, err := decodeWALRecord(body)
+		if err != nil {
+			return offset, broken()
+		}
+		if err = fn(rec); err != nil {
+			return offset, err
+		}
+		offset = end
+	}
+}
+
+// append writes rec and syncs it, so a returned record survives power loss.
+func (w *wal) append(rec walRecord) error {
+	body := encodeWALRecord(rec)
+	buf := make([]byte, 8+len(body))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(body)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(body))
+	copy(buf[8:], body)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.f.Write(buf); err != nil {
+		return err
+	}
+	return w.f.Sync()
+}
+
+// reset starts an empty log at path, called after SaveIndex succeeded.
+func (w *wal) reset(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	old := w.f
+	w.path, w.f = path, f
+	return old.Close()
+}
+
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
+
+func encodeWALRecord(rec walRecord) []byte {
+	size := 5
+	if rec.op == walInsert {
+		size += 4 + 8*len(rec.vec)
+	}
+	body := make([]byte, size)
+	body[0] = byte(rec.op)
+	binary.LittleEndian.PutUint32(body[1:5], uint32(rec.id))
+	if rec.op == walInsert {
+		binary.LittleEndian.PutUint32(body[5:9], uint32(len(rec.vec)))
+		for i, v := range rec.vec {
+			binary.LittleEndian.PutUint64(body[9+8*i:], math.Float64bits(v))
+		}
+	}
+	return body
+}
+
+func decodeWALRecord(body []byte) (walRecord, error) {
+	rec := walRecord{
+		op: walOp(body[0]),
+		id: uint(binary.LittleEndian.Uint32(body[1:5])),
+	}
+	switch rec.op {
+	case walInsert:
+		if len(body) < 9 {
+			return rec, ErrWALCorrupted
+		}
+		dim := int(binary.LittleEndian.Uint32(body[5:9]))
+		if len(body) != 9+8*dim {
+			return rec, ErrWALCorrupted
+		}
+		rec.vec = make([]float64, dim)
+		for i := range rec.vec {
+			rec.vec[i] = math.Float64frombits(binary.LittleEndian.Uint64(body[9+8*i:]))
+		}
+	case walRemove, walCreateIndex:
+	default:
+		return rec, ErrWALCorrupted
+	}
+	return rec, nil
+}
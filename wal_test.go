@@ -0,0 +1,193 @@
+//
+// Copyright (C) 2017 Yahoo Japan Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build cgo && !purego
+
+package gongt
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestWriteAheadLog(t *testing.T) {
+	vectors := [][]float64{
+		{1, 0, 0, 0, 0, 0},
+		{0, 1, 0, 0, 0, 0},
+		{0, 0, 1, 0, 0, 0},
+	}
+
+	tmpdir, err := ioutil.TempDir("", "tmpdir")
+	if err != nil {
+		t.Errorf("Unexpected error: TestWriteAheadLog(%v)", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	ngt := New(tmpdir).SetObjectType(Uint8).SetDimension(6).SetWriteAheadLog(true).Open()
+	if errs := ngt.GetErrors(); len(errs) > 0 {
+		t.Errorf("Unexpected error: TestWriteAheadLog(%v)", errs)
+	}
+	if _, errs := ngt.BulkInsert(vectors); len(errs) > 0 {
+		t.Errorf("Unexpected error: TestWriteAheadLog(%v)", errs)
+	}
+	if err := ngt.CreateIndex(poolSize); err != nil {
+		t.Errorf("Unexpected error: TestWriteAheadLog(%v)", err)
+	}
+	if err := ngt.Remove(2); err != nil {
+		t.Errorf("Unexpected error: TestWriteAheadLog(%v)", err)
+	}
+	// close without SaveIndex
+	ngt.Close()
+
+	ngt = New(tmpdir).SetWriteAheadLog(true).Open()
+	defer ngt.Close()
+	if errs := ngt.GetErrors(); len(errs) > 0 {
+		t.Errorf("Unexpected error: TestWriteAheadLog(%v)", errs)
+	}
+	for _, id := range []int{1, 3} {
+		vec, err := ngt.GetVector(id)
+		if err != nil {
+			t.Errorf("Unexpected error: TestWriteAheadLog(%v)", err)
+		}
+		if !reflect.DeepEqual(vec, vectors[id-1]) {
+			t.Errorf("TestWriteAheadLog(%v): %v, wanted: %v", id, vec, vectors[id-1])
+		}
+	}
+	if _, err := ngt.GetVector(2); err == nil {
+		t.Errorf("TestWriteAheadLog(2): removed object is replayed")
+	}
+
+	if err := ngt.SaveIndex(); err != nil {
+		t.Errorf("Unexpected error: TestWriteAheadLog(%v)", err)
+	}
+	info, err := os.Stat(filepath.Join(tmpdir, WALFile))
+	if err != nil {
+		t.Errorf("Unexpected error: TestWriteAheadLog(%v)", err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("TestWriteAheadLog: log size %v after SaveIndex, wanted: 0", info.Size())
+	}
+}
+
+func TestWALReplayTornTail(t *testing.T) {
+	tests := []walRecord{
+		{op: walInsert, id: 1, vec: []float64{1, 2, 3}},
+		{op: walRemove, id: 1},
+		{op: walCreateIndex, id: 2},
+	}
+
+	f, err := ioutil.TempFile("", "wal")
+	if err != nil {
+		t.Errorf("Unexpected error: TestWALReplayTornTail(%v)", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	w := &wal{mu: &sync.Mutex{}, path: f.Name(), f: f}
+	for _, rec := range tests {
+		if err := w.append(rec); err != nil {
+			t.Errorf("Unexpected error: TestWALReplayTornTail(%v)", err)
+		}
+	}
+	valid, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		t.Errorf("Unexpected error: TestWALReplayTornTail(%v)", err)
+	}
+	// half written record
+	if _, err := f.Write([]byte{0xff, 0, 0, 0, 1, 2}); err != nil {
+		t.Errorf("Unexpected error: TestWALReplayTornTail(%v)", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Errorf("Unexpected error: TestWALReplayTornTail(%v)", err)
+	}
+
+	got := make([]walRecord, 0, len(tests))
+	offset, err := w.replay(func(rec walRecord) error {
+		got = append(got, rec)
+		return nil
+	})
+	if err != ErrWALCorrupted {
+		t.Errorf("TestWALReplayTornTail: %v, wanted: %v", err, ErrWALCorrupted)
+	}
+	if offset != valid {
+		t.Errorf("TestWALReplayTornTail: offset %v, wanted: %v", offset, valid)
+	}
+	if !reflect.DeepEqual(got, tests) {
+		t.Errorf("TestWALReplayTornTail: %v, wanted: %v", got, tests)
+	}
+}
+
+func TestWALReplayCorrupted(t *testing.T) {
+	records := []walRecord{
+		{op: walInsert, id: 1, vec: []float64{1, 2, 3}},
+		{op: walRemove, id: 1},
+		{op: walCreateIndex, id: 2},
+	}
+	tests := []struct {
+		broken int
+		want   int
+		tail   bool
+	}{
+		{0, 0, false},
+		{1, 1, false},
+		{2, 2, true},
+	}
+
+	for _, tt := range tests {
+		f, err := ioutil.TempFile("", "wal")
+		if err != nil {
+			t.Fatalf("Unexpected error: TestWALReplayCorrupted(%v)", err)
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+
+		w := &wal{mu: &sync.Mutex{}, path: f.Name(), f: f}
+		var offset int64
+		for i, rec := range records {
+			if i == tt.broken {
+				offset, _ = f.Seek(0, io.SeekCurrent)
+			}
+			if err := w.append(rec); err != nil {
+				t.Errorf("Unexpected error: TestWALReplayCorrupted(%v)", err)
+			}
+		}
+		// flip the op byte after length and crc32
+		if _, err := f.WriteAt([]byte{0xff}, offset+8); err != nil {
+			t.Errorf("Unexpected error: TestWALReplayCorrupted(%v)", err)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			t.Errorf("Unexpected error: TestWALReplayCorrupted(%v)", err)
+		}
+
+		got := 0
+		valid, err := w.replay(func(rec walRecord) error {
+			got++
+			return nil
+		})
+		if tail := err == ErrWALCorrupted; tail != tt.tail || !errors.Is(err, ErrWALCorrupted) {
+			t.Errorf("TestWALReplayCorrupted(%v): %v, wanted tail: %v", tt.broken, err, tt.tail)
+		}
+		if valid != offset || got != tt.want {
+			t.Errorf("TestWALReplayCorrupted(%v): offset %v and %v records, wanted: %v and %v", tt.broken, valid, got, offset, tt.want)
+		}
+	}
+}